@@ -129,7 +129,7 @@ func (a *Album) Search(artist, album string, page int) (as *albumSearch, err err
 	p := &lastfm.Provider{
 		Method:   "album.search",
 		Params:   params,
-		Response: as,
+		Response: &as,
 		Type:     "GET",
 	}
 	err = a.api.Request(p)
@@ -0,0 +1,46 @@
+package album
+
+import (
+	"strconv"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// List returns the albums of a Search response as a slice of lastfm.SearchMatch.
+// LastFM does not return listener counts for album searches.
+func (as *albumSearch) List() (list []lastfm.SearchMatch) {
+	if as == nil {
+		return nil
+	}
+	for _, a := range as.Results.Albummatches.Album {
+		list = append(list, lastfm.SearchMatch{
+			Name:   a.Name,
+			Artist: a.Artist,
+			Mbid:   a.Mbid,
+			URL:    a.URL,
+			Image:  lastfm.LargestImage(a.Image),
+		})
+	}
+	return
+}
+
+// Total returns the total number of albums matching a Search request.
+func (as *albumSearch) Total() int {
+	if as == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(as.Results.OpensearchTotalResults)
+	return n
+}
+
+// TotalPages returns the number of pages available for a Search request.
+func (as *albumSearch) TotalPages() int {
+	if as == nil {
+		return 0
+	}
+	perPage, _ := strconv.Atoi(as.Results.OpensearchItemsPerPage)
+	if perPage <= 0 {
+		return 0
+	}
+	return (as.Total() + perPage - 1) / perPage
+}
@@ -22,10 +22,7 @@ type attributes struct {
 	Album  string `json:"album"`
 }
 
-type image struct {
-	Text string `json:"#text"`
-	Size string `json:"size"`
-}
+type image = lastfm.Image
 
 type streamable struct {
 	Text      string `json:"#text"`
@@ -42,6 +39,7 @@ type albumInfo struct {
 	Album struct {
 		Name          string  `json:"name"`
 		Artist        string  `json:"artist"`
+		Mbid          string  `json:"mbid"`
 		URL           string  `json:"url"`
 		Image         []image `json:"image"`
 		Listeners     string  `json:"listeners"`
@@ -0,0 +1,45 @@
+package artist
+
+import (
+	"strconv"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// List returns the artists of a Search response as a slice of lastfm.SearchMatch.
+func (as *artistSearch) List() (list []lastfm.SearchMatch) {
+	if as == nil {
+		return nil
+	}
+	for _, a := range as.Results.Artistmatches.Artist {
+		list = append(list, lastfm.SearchMatch{
+			Name:      a.Name,
+			Mbid:      a.Mbid,
+			URL:       a.URL,
+			Image:     lastfm.LargestImage(a.Image),
+			Listeners: lastfm.ParseInt(a.Listeners),
+		})
+	}
+	return
+}
+
+// Total returns the total number of artists matching a Search request.
+func (as *artistSearch) Total() int {
+	if as == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(as.Results.OpensearchTotalResults)
+	return n
+}
+
+// TotalPages returns the number of pages available for a Search request.
+func (as *artistSearch) TotalPages() int {
+	if as == nil {
+		return 0
+	}
+	perPage, _ := strconv.Atoi(as.Results.OpensearchItemsPerPage)
+	if perPage <= 0 {
+		return 0
+	}
+	return (as.Total() + perPage - 1) / perPage
+}
@@ -21,10 +21,7 @@ type artist struct {
 	URL        string  `json:"url"`
 }
 
-type image struct {
-	Text string `json:"#text"`
-	Size string `json:"size"`
-}
+type image = lastfm.Image
 
 type tags struct {
 	Count int    `json:"count,omitempty"`
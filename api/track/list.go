@@ -0,0 +1,46 @@
+package track
+
+import (
+	"strconv"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// List returns the tracks of a Search response as a slice of lastfm.SearchMatch.
+func (ts *trackSearch) List() (list []lastfm.SearchMatch) {
+	if ts == nil {
+		return nil
+	}
+	for _, t := range ts.Results.Trackmatches.Track {
+		list = append(list, lastfm.SearchMatch{
+			Name:      t.Name,
+			Artist:    t.Artist,
+			Mbid:      t.Mbid,
+			URL:       t.URL,
+			Image:     lastfm.LargestImage(t.Image),
+			Listeners: lastfm.ParseInt(t.Listeners),
+		})
+	}
+	return
+}
+
+// Total returns the total number of tracks matching a Search request.
+func (ts *trackSearch) Total() int {
+	if ts == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(ts.Results.OpensearchTotalResults)
+	return n
+}
+
+// TotalPages returns the number of pages available for a Search request.
+func (ts *trackSearch) TotalPages() int {
+	if ts == nil {
+		return 0
+	}
+	perPage, _ := strconv.Atoi(ts.Results.OpensearchItemsPerPage)
+	if perPage <= 0 {
+		return 0
+	}
+	return (ts.Total() + perPage - 1) / perPage
+}
@@ -19,10 +19,7 @@ type artist struct {
 	URL  string `json:"url"`
 }
 
-type image struct {
-	Text string `json:"#text"`
-	Size string `json:"size"`
-}
+type image = lastfm.Image
 
 type streamable struct {
 	Text      string `json:"#text"`
@@ -142,8 +139,8 @@ type trackScrobble struct {
 		} `xml:"albumArtist"`
 		TimeStamp      string `xml:"timestamp"`
 		IgnoredMessage struct {
-			Corrected string `xml:"corrected,attr"`
-			Body      string `xml:",chardata"`
+			Code string `xml:"code,attr"`
+			Body string `xml:",chardata"`
 		} `xml:"ignoredMessage"`
 	} `xml:"scrobble"`
 }
@@ -193,7 +190,7 @@ type trackUpdateNowPlaying struct {
 		Name      string `xml:",chardata"`
 	} `xml:"albumArtist"`
 	IgnoredMessage struct {
-		Corrected string `xml:"corrected,attr"`
-		Body      string `xml:",chardata"`
+		Code string `xml:"code,attr"`
+		Body string `xml:",chardata"`
 	} `xml:"ignoredMessage"`
 }
@@ -0,0 +1,29 @@
+package track
+
+import (
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// scrobbler adapts Track to the lastfm.Scrobbler interface.
+type scrobbler struct {
+	track *Track
+}
+
+// Scrobbler returns the Track as a lastfm.Scrobbler, for use with other
+// scrobbling services through lastfm.FanOut.
+func (t *Track) Scrobbler() lastfm.Scrobbler {
+	return &scrobbler{track: t}
+}
+
+func (s *scrobbler) NowPlaying(scrobble lastfm.Scrobble) (err error) {
+	_, err = s.track.UpdateNowPlaying(scrobble)
+	return
+}
+
+func (s *scrobbler) Scrobble(scrobbles []lastfm.Scrobble) ([]lastfm.ScrobbleResult, error) {
+	return s.track.ScrobbleAll(scrobbles)
+}
+
+func (s *scrobbler) Love(artist, track string) error {
+	return s.track.Love(artist, track)
+}
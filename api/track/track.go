@@ -166,8 +166,12 @@ func (t *Track) RemoveTag(artist, track, tag string) (err error) {
 // Scrobble adds a track-play to the user's profile on LastFM
 // for each track in the provided scrobble list.
 //
-// The scrobble list needs to be a slice of the Scrobble struct.
+// The scrobble list needs to be a slice of the Scrobble struct, holding
+// at most lastfm.MaxScrobbleBatch scrobbles.
 func (t *Track) Scrobble(scrobbleList []lastfm.Scrobble) (ts *trackScrobble, err error) {
+	if len(scrobbleList) > lastfm.MaxScrobbleBatch {
+		return nil, fmt.Errorf("Scrobble limit exceeded. Maximum Scrobbles Allowed: %v", lastfm.MaxScrobbleBatch)
+	}
 	params := map[string]string{}
 	for idx, scrobble := range scrobbleList {
 		if scrobble.Artist == "" || scrobble.Track == "" || scrobble.Timestamp <= 0 {
@@ -185,6 +189,7 @@ func (t *Track) Scrobble(scrobbleList []lastfm.Scrobble) (ts *trackScrobble, err
 		params[fmt.Sprintf("trackNumber[%v]", idx+1)] = strconv.Itoa(scrobble.TrackNumber)
 		params[fmt.Sprintf("track[%v]", idx+1)] = scrobble.Track
 	}
+	ts = &trackScrobble{}
 	p := &lastfm.Provider{
 		Method:   "track.scrobble",
 		Params:   params,
@@ -196,6 +201,34 @@ func (t *Track) Scrobble(scrobbleList []lastfm.Scrobble) (ts *trackScrobble, err
 	return
 }
 
+// ScrobbleAll scrobbles every track in the provided scrobble list, in batches
+// of lastfm.MaxScrobbleBatch, and returns the outcome of each scrobble in the
+// order of the list. Scrobbling stops at the first failing batch.
+func (t *Track) ScrobbleAll(scrobbleList []lastfm.Scrobble) (results []lastfm.ScrobbleResult, err error) {
+	for start := 0; start < len(scrobbleList); start += lastfm.MaxScrobbleBatch {
+		end := start + lastfm.MaxScrobbleBatch
+		if end > len(scrobbleList) {
+			end = len(scrobbleList)
+		}
+		ts, err := t.Scrobble(scrobbleList[start:end])
+		if err != nil {
+			return results, err
+		}
+		for i := start; i < end; i++ {
+			result := lastfm.ScrobbleResult{Accepted: true}
+			if ts != nil && i-start < len(ts.Scrobbles) {
+				ignored := ts.Scrobbles[i-start].IgnoredMessage
+				code, _ := strconv.Atoi(ignored.Code)
+				result.IgnoredCode = lastfm.IgnoredReason(code)
+				result.IgnoredMessage = strings.TrimSpace(ignored.Body)
+				result.Accepted = result.IgnoredCode == lastfm.IgnoredNone
+			}
+			results = append(results, result)
+		}
+	}
+	return
+}
+
 // Search searches for a track by artist and track name on LastFM.
 func (t *Track) Search(artist, track string, page int) (ts *trackSearch, err error) {
 	params := map[string]string{
@@ -207,7 +240,7 @@ func (t *Track) Search(artist, track string, page int) (ts *trackSearch, err err
 	p := &lastfm.Provider{
 		Method:   "track.search",
 		Params:   params,
-		Response: ts,
+		Response: &ts,
 		Type:     "GET",
 	}
 	err = t.api.Request(p)
@@ -250,6 +283,7 @@ func (t *Track) UpdateNowPlaying(scrobble lastfm.Scrobble) (tnp *trackUpdateNowP
 		"track":       scrobble.Track,
 		"trackNumber": strconv.Itoa(scrobble.TrackNumber),
 	}
+	tnp = &trackUpdateNowPlaying{}
 	p := &lastfm.Provider{
 		Method:   "track.updatenowplaying",
 		Params:   params,
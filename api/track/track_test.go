@@ -0,0 +1,56 @@
+package track
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+func newStub(t *testing.T, body string) *Track {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><lfm status="ok">`+body+`</lfm>`)
+	}))
+	t.Cleanup(srv.Close)
+	client := lastfm.NewWithService(lastfm.Service{Name: "stub", BaseURL: srv.URL}, "key", "secret")
+	return New(&client, "user", false)
+}
+
+func TestScrobbleAll(t *testing.T) {
+	tr := newStub(t, `<scrobbles accepted="1" ignored="1">`+
+		`<scrobble><track corrected="0">T</track><artist corrected="0">A</artist><timestamp>1</timestamp><ignoredMessage code="0"></ignoredMessage></scrobble>`+
+		`<scrobble><track corrected="0">T</track><artist corrected="0">A</artist><timestamp>2</timestamp><ignoredMessage code="3">Timestamp too old</ignoredMessage></scrobble>`+
+		`</scrobbles>`)
+
+	results, err := tr.ScrobbleAll([]lastfm.Scrobble{
+		{Artist: "A", Track: "T", Timestamp: 1},
+		{Artist: "A", Track: "T", Timestamp: 2},
+	})
+	if err != nil {
+		t.Fatalf("ScrobbleAll: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %v results, want 2", len(results))
+	}
+	if !results[0].Accepted {
+		t.Errorf("first scrobble not accepted: %+v", results[0])
+	}
+	if results[1].Accepted || results[1].IgnoredCode != lastfm.IgnoredTimestampTooOld || results[1].IgnoredMessage != "Timestamp too old" {
+		t.Errorf("second scrobble: got %+v", results[1])
+	}
+}
+
+func TestUpdateNowPlaying(t *testing.T) {
+	tr := newStub(t, `<nowplaying><track corrected="0">T</track><artist corrected="1">A</artist><ignoredMessage code="0"></ignoredMessage></nowplaying>`)
+
+	tnp, err := tr.UpdateNowPlaying(lastfm.Scrobble{Artist: "a", Track: "T"})
+	if err != nil {
+		t.Fatalf("UpdateNowPlaying: %v", err)
+	}
+	if tnp.Artist.Name != "A" || tnp.Artist.Corrected != "1" {
+		t.Errorf("got artist %+v", tnp.Artist)
+	}
+}
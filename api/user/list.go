@@ -0,0 +1,159 @@
+package user
+
+import (
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// List returns the tracks of a GetRecentTracks response as a slice of
+// RecentTrack, in the order returned by LastFM.
+func (rt *recentTracks) List() (list []RecentTrack) {
+	if rt == nil {
+		return nil
+	}
+	for _, t := range rt.RecentTracks.Tracks {
+		artist := t.Artist.Name
+		if artist == "" {
+			artist = t.Artist.Text
+		}
+		var played time.Time
+		if t.Date.Uts != "" {
+			played = time.Unix(lastfm.ParseInt(t.Date.Uts), 0).UTC()
+		}
+		list = append(list, RecentTrack{
+			Artist:     artist,
+			ArtistMbid: t.Artist.Mbid,
+			Album:      t.Album.Text,
+			AlbumMbid:  t.Album.Mbid,
+			Track:      t.Name,
+			Mbid:       t.Mbid,
+			URL:        t.URL,
+			Time:       played,
+			NowPlaying: t.Attributes.NowPlaying == "true",
+			Loved:      t.Loved == "1",
+		})
+	}
+	return
+}
+
+// List returns the tracks of a GetLovedTracks response as a slice of
+// RecentTrack, where Time is the time the track was loved.
+func (lt *lovedTracks) List() (list []RecentTrack) {
+	if lt == nil {
+		return nil
+	}
+	for _, t := range lt.LovedTracks.Track {
+		list = append(list, RecentTrack{
+			Artist:     t.Artist.Name,
+			ArtistMbid: t.Artist.Mbid,
+			Track:      t.Name,
+			Mbid:       t.Mbid,
+			URL:        t.URL,
+			Time:       time.Unix(lastfm.ParseInt(t.Date.Uts), 0).UTC(),
+			Loved:      true,
+		})
+	}
+	return
+}
+
+// List returns the albums of a GetTopAlbums response as a slice of ChartEntry.
+func (ta *topAlbums) List() (list []ChartEntry) {
+	if ta == nil {
+		return nil
+	}
+	for _, a := range ta.TopAlbums.Album {
+		list = append(list, ChartEntry{
+			Rank:      int(lastfm.ParseInt(a.Attributes.Rank)),
+			Name:      a.Name,
+			Artist:    a.Artist.Name,
+			Mbid:      a.Mbid,
+			URL:       a.URL,
+			Playcount: lastfm.ParseInt(a.Playcount),
+		})
+	}
+	return
+}
+
+// List returns the artists of a GetTopArtists response as a slice of ChartEntry.
+func (ta *topArtists) List() (list []ChartEntry) {
+	if ta == nil {
+		return nil
+	}
+	for _, a := range ta.TopArtists.Artist {
+		list = append(list, ChartEntry{
+			Rank:      int(lastfm.ParseInt(a.Attributes.Rank)),
+			Name:      a.Name,
+			Mbid:      a.Mbid,
+			URL:       a.URL,
+			Playcount: lastfm.ParseInt(a.Playcount),
+		})
+	}
+	return
+}
+
+// List returns the tracks of a GetTopTracks response as a slice of ChartEntry.
+func (tt *topTracks) List() (list []ChartEntry) {
+	if tt == nil {
+		return nil
+	}
+	for _, t := range tt.TopTracks.Track {
+		list = append(list, ChartEntry{
+			Rank:      int(lastfm.ParseInt(t.Attributes.Rank)),
+			Name:      t.Name,
+			Artist:    t.Artist.Name,
+			Mbid:      t.Mbid,
+			URL:       t.URL,
+			Playcount: lastfm.ParseInt(t.Playcount),
+		})
+	}
+	return
+}
+
+// Total returns the total number of tracks matching a GetRecentTracks request.
+func (rt *recentTracks) Total() int {
+	if rt == nil {
+		return 0
+	}
+	return int(lastfm.ParseInt(rt.RecentTracks.Attributes.Total))
+}
+
+// TotalPages returns the number of pages available for a GetRecentTracks request.
+func (rt *recentTracks) TotalPages() int {
+	if rt == nil {
+		return 0
+	}
+	return int(lastfm.ParseInt(rt.RecentTracks.Attributes.TotalPages))
+}
+
+// TotalPages returns the number of pages available for a GetLovedTracks request.
+func (lt *lovedTracks) TotalPages() int {
+	if lt == nil {
+		return 0
+	}
+	return int(lastfm.ParseInt(lt.LovedTracks.Attributes.TotalPages))
+}
+
+// TotalPages returns the number of pages available for a GetTopAlbums request.
+func (ta *topAlbums) TotalPages() int {
+	if ta == nil {
+		return 0
+	}
+	return int(lastfm.ParseInt(ta.TopAlbums.Attributes.TotalPages))
+}
+
+// TotalPages returns the number of pages available for a GetTopArtists request.
+func (ta *topArtists) TotalPages() int {
+	if ta == nil {
+		return 0
+	}
+	return int(lastfm.ParseInt(ta.TopArtists.Attributes.TotalPages))
+}
+
+// TotalPages returns the number of pages available for a GetTopTracks request.
+func (tt *topTracks) TotalPages() int {
+	if tt == nil {
+		return 0
+	}
+	return int(lastfm.ParseInt(tt.TopTracks.Attributes.TotalPages))
+}
@@ -1,6 +1,8 @@
 package user
 
 import (
+	"time"
+
 	"git.maych.in/thunderbottom/lastfm-go"
 )
 
@@ -10,6 +12,65 @@ type User struct {
 	Username string
 }
 
+// Period is a time period for the top charts of a user.
+type Period string
+
+// Periods supported by the top charts of a user.
+const (
+	PeriodOverall Period = "overall"
+	Period7Day    Period = "7day"
+	Period1Month  Period = "1month"
+	Period3Month  Period = "3month"
+	Period6Month  Period = "6month"
+	Period12Month Period = "12month"
+)
+
+// RangeChart contains a top chart of a user aggregated from the weekly
+// charts covering a date range.
+//
+// LastFM weekly charts have fixed boundaries, so the weeks at either end
+// of the range may extend past it. StartApproximated and EndApproximated
+// report whether plays before From or after To may be included.
+type RangeChart struct {
+	From              time.Time
+	To                time.Time
+	Weeks             []Week
+	StartApproximated bool
+	EndApproximated   bool
+	Entries           []ChartEntry
+}
+
+// ChartEntry is an artist, album or track in a top chart. Artist is empty
+// for artist charts.
+type ChartEntry struct {
+	Rank      int    `json:"rank"`
+	Name      string `json:"name"`
+	Artist    string `json:"artist,omitempty"`
+	Mbid      string `json:"mbid,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Playcount int64  `json:"playcount"`
+}
+
+// RecentTrack is a single play from the recent tracks of a user.
+type RecentTrack struct {
+	Artist     string    `json:"artist"`
+	ArtistMbid string    `json:"artist_mbid,omitempty"`
+	Album      string    `json:"album,omitempty"`
+	AlbumMbid  string    `json:"album_mbid,omitempty"`
+	Track      string    `json:"track"`
+	Mbid       string    `json:"mbid,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	Time       time.Time `json:"time"`
+	NowPlaying bool      `json:"now_playing,omitempty"`
+	Loved      bool      `json:"loved,omitempty"`
+}
+
+// Week is the date range of a weekly chart.
+type Week struct {
+	From time.Time
+	To   time.Time
+}
+
 type artist struct {
 	Image      []image `json:"image,omitempty"`
 	Mbid       string  `json:"mbid,omitempty"`
@@ -98,7 +159,7 @@ type userInfo struct {
 		Realname   string  `json:"realname"`
 		Registered struct {
 			Text     string `json:"#text"`
-			Unixtime string `json:"#text"`
+			Unixtime string `json:"unixtime"`
 		} `json:"registered"`
 		Subscriber string `json:"subscriber"`
 		Type       string `json:"type"`
@@ -0,0 +1,146 @@
+package user
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// Validate returns an error if p is not one of the periods supported by LastFM.
+func (p Period) Validate() error {
+	switch p {
+	case PeriodOverall, Period7Day, Period1Month, Period3Month, Period6Month, Period12Month:
+		return nil
+	}
+	return fmt.Errorf("invalid period %q, must be one of overall, 7day, 1month, 3month, 6month, 12month", string(p))
+}
+
+// GetTopAlbumsRange fetches the top albums listened to by the user from
+// LastFM between from and to, by adding up the weekly album charts.
+func (u *User) GetTopAlbumsRange(from, to time.Time) (rc *RangeChart, err error) {
+	return u.getRange(from, to, func(week Week, add func(ChartEntry)) error {
+		wac, err := u.GetWeeklyAlbumChart(week.From.Unix(), week.To.Unix())
+		if err != nil {
+			return err
+		}
+		for _, album := range wac.WeeklyAlbumChart.Album {
+			add(ChartEntry{
+				Name:      album.Name,
+				Artist:    album.Artist.Text,
+				Mbid:      album.Mbid,
+				URL:       album.URL,
+				Playcount: lastfm.ParseInt(album.Playcount),
+			})
+		}
+		return nil
+	})
+}
+
+// GetTopArtistsRange fetches the top artists listened to by the user from
+// LastFM between from and to, by adding up the weekly artist charts.
+func (u *User) GetTopArtistsRange(from, to time.Time) (rc *RangeChart, err error) {
+	return u.getRange(from, to, func(week Week, add func(ChartEntry)) error {
+		wac, err := u.GetWeeklyArtistChart(week.From.Unix(), week.To.Unix())
+		if err != nil {
+			return err
+		}
+		for _, artist := range wac.WeeklyArtistChart.Artist {
+			add(ChartEntry{
+				Name:      artist.Name,
+				Mbid:      artist.Mbid,
+				URL:       artist.URL,
+				Playcount: lastfm.ParseInt(artist.Playcount),
+			})
+		}
+		return nil
+	})
+}
+
+// GetTopTracksRange fetches the top tracks listened to by the user from
+// LastFM between from and to, by adding up the weekly track charts.
+func (u *User) GetTopTracksRange(from, to time.Time) (rc *RangeChart, err error) {
+	return u.getRange(from, to, func(week Week, add func(ChartEntry)) error {
+		wtc, err := u.GetWeeklyTrackChart(week.From.Unix(), week.To.Unix())
+		if err != nil {
+			return err
+		}
+		for _, track := range wtc.WeeklyTrackChart.Track {
+			add(ChartEntry{
+				Name:      track.Name,
+				Artist:    track.Artist.Text,
+				Mbid:      track.Mbid,
+				URL:       track.URL,
+				Playcount: lastfm.ParseInt(track.Playcount),
+			})
+		}
+		return nil
+	})
+}
+
+// getRange selects the weekly charts overlapping from and to, calls fetch
+// for each of them, and ranks the entries by their total playcount.
+func (u *User) getRange(from, to time.Time, fetch func(Week, func(ChartEntry)) error) (rc *RangeChart, err error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid range: %v is not before %v", from, to)
+	}
+	wcl, err := u.GetWeeklyChartList()
+	if err != nil {
+		return nil, err
+	}
+
+	rc = &RangeChart{From: from, To: to}
+	for _, chart := range wcl.WeeklyChartList.Chart {
+		week := Week{
+			From: time.Unix(lastfm.ParseInt(chart.From), 0),
+			To:   time.Unix(lastfm.ParseInt(chart.To), 0),
+		}
+		if !week.From.Before(to) || !week.To.After(from) {
+			continue
+		}
+		rc.Weeks = append(rc.Weeks, week)
+	}
+	sort.Slice(rc.Weeks, func(i, j int) bool {
+		return rc.Weeks[i].From.Before(rc.Weeks[j].From)
+	})
+	if len(rc.Weeks) == 0 {
+		return rc, nil
+	}
+	rc.StartApproximated = rc.Weeks[0].From.Before(from)
+	rc.EndApproximated = rc.Weeks[len(rc.Weeks)-1].To.After(to)
+
+	totals := map[string]*ChartEntry{}
+	add := func(entry ChartEntry) {
+		key := strings.ToLower(entry.Artist) + "\x00" + strings.ToLower(entry.Name)
+		if total, ok := totals[key]; ok {
+			total.Playcount += entry.Playcount
+			return
+		}
+		totals[key] = &entry
+	}
+	for _, week := range rc.Weeks {
+		if err = fetch(week, add); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range totals {
+		rc.Entries = append(rc.Entries, *entry)
+	}
+	sort.Slice(rc.Entries, func(i, j int) bool {
+		a, b := rc.Entries[i], rc.Entries[j]
+		if a.Playcount != b.Playcount {
+			return a.Playcount > b.Playcount
+		}
+		if a.Artist != b.Artist {
+			return a.Artist < b.Artist
+		}
+		return a.Name < b.Name
+	})
+	for i := range rc.Entries {
+		rc.Entries[i].Rank = i + 1
+	}
+	return
+}
@@ -100,15 +100,55 @@ func (u *User) GetRecentTracks(extended bool, page int) (rt *recentTracks, err e
 	return
 }
 
+// GetRecentTracksRange fetches a list of tracks listened to by the user
+// from LastFM between from and to, expressed in unixtime. A from or to
+// of 0 leaves the respective end of the range open.
+func (u *User) GetRecentTracksRange(extended bool, from, to int64, page int) (rt *recentTracks, err error) {
+	params := map[string]string{
+		"user":     u.Username,
+		"limit":    u.api.GetLimit(),
+		"extended": u.api.Bool2strint(extended),
+		"page":     strconv.Itoa(page),
+	}
+	if from > 0 {
+		params["from"] = strconv.FormatInt(from, 10)
+	}
+	if to > 0 {
+		params["to"] = strconv.FormatInt(to, 10)
+	}
+	p := &lastfm.Provider{
+		Method:   "user.getrecenttracks",
+		Params:   params,
+		Response: &rt,
+		Type:     "GET",
+	}
+	err = u.api.Request(p)
+
+	return
+}
+
 // GetTopAlbums fetches a list of top albums listened to by the user
 // from LastFM for the specified period.
 //
+// period needs to be either of `overall`, `7day`, `1month`, `3month`,
+// `6month`, `12month`, and defaults to `overall` when empty.
 func (u *User) GetTopAlbums(period string, page int) (ta *topAlbums, err error) {
+	return u.GetTopAlbumsPeriod(Period(period), page)
+}
+
+// GetTopAlbumsPeriod is GetTopAlbums for a Period constant.
+func (u *User) GetTopAlbumsPeriod(period Period, page int) (ta *topAlbums, err error) {
+	if period == "" {
+		period = PeriodOverall
+	}
+	if err = period.Validate(); err != nil {
+		return nil, err
+	}
 	params := map[string]string{
 		"user":   u.Username,
 		"limit":  u.api.GetLimit(),
 		"page":   strconv.Itoa(page),
-		"period": period,
+		"period": string(period),
 	}
 	p := &lastfm.Provider{
 		Method:   "user.gettopalbums",
@@ -125,13 +165,24 @@ func (u *User) GetTopAlbums(period string, page int) (ta *topAlbums, err error)
 // from LastFM for the specified period.
 //
 // period needs to be either of `overall`, `7day`, `1month`, `3month`,
-// `6month`, `12month`.
+// `6month`, `12month`, and defaults to `overall` when empty.
 func (u *User) GetTopArtists(period string, page int) (ta *topArtists, err error) {
+	return u.GetTopArtistsPeriod(Period(period), page)
+}
+
+// GetTopArtistsPeriod is GetTopArtists for a Period constant.
+func (u *User) GetTopArtistsPeriod(period Period, page int) (ta *topArtists, err error) {
+	if period == "" {
+		period = PeriodOverall
+	}
+	if err = period.Validate(); err != nil {
+		return nil, err
+	}
 	params := map[string]string{
 		"user":   u.Username,
 		"limit":  u.api.GetLimit(),
 		"page":   strconv.Itoa(page),
-		"period": period,
+		"period": string(period),
 	}
 	p := &lastfm.Provider{
 		Method:   "user.gettopartists",
@@ -145,16 +196,27 @@ func (u *User) GetTopArtists(period string, page int) (ta *topArtists, err error
 }
 
 // GetTopTracks fetches a list of top tracks listened to by the user
-//from LastFM for the specified period.
+// from LastFM for the specified period.
 //
 // period needs to be either of `overall`, `7day`, `1month`, `3month`,
-// `6month`, `12month`.
+// `6month`, `12month`, and defaults to `overall` when empty.
 func (u *User) GetTopTracks(period string, page int) (tt *topTracks, err error) {
+	return u.GetTopTracksPeriod(Period(period), page)
+}
+
+// GetTopTracksPeriod is GetTopTracks for a Period constant.
+func (u *User) GetTopTracksPeriod(period Period, page int) (tt *topTracks, err error) {
+	if period == "" {
+		period = PeriodOverall
+	}
+	if err = period.Validate(); err != nil {
+		return nil, err
+	}
 	params := map[string]string{
 		"user":   u.Username,
 		"limit":  u.api.GetLimit(),
 		"page":   strconv.Itoa(page),
-		"period": period,
+		"period": string(period),
 	}
 	p := &lastfm.Provider{
 		Method:   "user.gettoptracks",
@@ -0,0 +1,331 @@
+// Package archive keeps a local copy of the scrobble history of a LastFM user,
+// synchronized incrementally and queryable without network access.
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+// Sync fetches the scrobbles made since the newest archived scrobble and
+// appends them to the archive.
+//
+// Before fetching, the segments overlapping the RescanWindow are compared
+// against the scrobble counts reported by LastFM. Segments which differ,
+// because scrobbles were deleted or missed, are fetched again and replaced.
+func (a *Archive) Sync() (report *SyncReport, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	report = &SyncReport{}
+	if n := len(a.index.Segments); n > 0 {
+		windowStart := a.index.Segments[n-1].Last - int64(a.RescanWindow/time.Second)
+		for i := range a.index.Segments {
+			if _, to := a.segmentRange(i); to <= windowStart {
+				continue
+			}
+			if err = a.verifySegment(i, report); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	var from int64
+	if n := len(a.index.Segments); n > 0 {
+		from = a.index.Segments[n-1].Last
+	}
+	plays, err := a.fetch(from, 0)
+	if err != nil {
+		return report, err
+	}
+	if from > 0 {
+		existing, err := a.playsAt(from)
+		if err != nil {
+			return report, err
+		}
+		plays = withoutPlays(plays, existing)
+	}
+	if err = a.append(plays); err != nil {
+		return report, err
+	}
+	report.Added += len(plays)
+
+	a.index.LastSync = time.Now().UTC()
+	err = a.saveIndex()
+	return
+}
+
+// Verify compares every segment of the archive, along with the time up to
+// the next segment, against the scrobble counts reported by LastFM, and
+// replaces the segments which differ.
+func (a *Archive) Verify() (report *SyncReport, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	report = &SyncReport{}
+	for i := range a.index.Segments {
+		if err = a.verifySegment(i, report); err != nil {
+			return
+		}
+	}
+	err = a.saveIndex()
+	return
+}
+
+// Query returns the archived scrobbles matching q, in chronological order.
+func (a *Archive) Query(q Query) (plays []user.RecentTrack, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, seg := range a.index.Segments {
+		if (!q.From.IsZero() && seg.Last < q.From.Unix()) || (!q.To.IsZero() && seg.First > q.To.Unix()) {
+			continue
+		}
+		list, err := a.readSegment(seg)
+		if err != nil {
+			return nil, err
+		}
+		for _, play := range list {
+			if !q.From.IsZero() && play.Time.Before(q.From) {
+				continue
+			}
+			if !q.To.IsZero() && play.Time.After(q.To) {
+				continue
+			}
+			if q.Artist != "" && !strings.EqualFold(play.Artist, q.Artist) {
+				continue
+			}
+			if q.Track != "" && !strings.EqualFold(play.Track, q.Track) {
+				continue
+			}
+			plays = append(plays, play)
+		}
+	}
+	return
+}
+
+// Segments returns the segments of the archive in chronological order.
+func (a *Archive) Segments() []Segment {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Segment(nil), a.index.Segments...)
+}
+
+// segmentRange returns the half-open range of unixtimes [from, to) verified
+// for the i-th segment. The ranges of consecutive segments meet without gaps:
+// each one ends where the next segment starts, and the first one starts at
+// the beginning of the history.
+func (a *Archive) segmentRange(i int) (from, to int64) {
+	segments := a.index.Segments
+	if i > 0 {
+		from = segments[i].First
+	}
+	if i+1 < len(segments) {
+		return from, segments[i+1].First
+	}
+	return from, segments[i].Last + 1
+}
+
+// verifySegment refetches the scrobbles in the range of the i-th segment when
+// their count on LastFM differs from the archive. Scrobbles in the range are
+// stored in the i-th segment, and removed from the neighbouring segments
+// sharing a boundary second with it.
+func (a *Archive) verifySegment(i int, report *SyncReport) error {
+	from, to := a.segmentRange(i)
+	if to <= from {
+		return nil
+	}
+
+	// The archived scrobbles in the range, and the ones outside of it, of
+	// every segment overlapping the range.
+	overlapping := map[int][]user.RecentTrack{}
+	count := 0
+	for j, seg := range a.index.Segments {
+		if j != i && (seg.Count == 0 || seg.First >= to || seg.Last < from) {
+			continue
+		}
+		plays, err := a.readSegment(seg)
+		if err != nil && !(os.IsNotExist(err) && seg.Count == 0) {
+			return err
+		}
+		var kept []user.RecentTrack
+		for _, play := range plays {
+			if ts := play.Time.Unix(); ts >= from && ts < to {
+				count++
+			} else {
+				kept = append(kept, play)
+			}
+		}
+		overlapping[j] = kept
+	}
+
+	rt, err := a.user.GetRecentTracksRange(false, from, to-1, 1)
+	if err != nil {
+		return err
+	}
+	if rt.Total() == count {
+		return nil
+	}
+
+	plays, err := a.fetch(from, to-1)
+	if err != nil {
+		return err
+	}
+	for j, kept := range overlapping {
+		if j == i {
+			// Scrobbles of the segment outside its range are at the start of
+			// the next segment, after the fetched ones.
+			kept = append(plays, kept...)
+		} else if len(kept) == a.index.Segments[j].Count {
+			continue
+		}
+		updated, err := a.rewriteSegment(a.index.Segments[j], kept)
+		if err != nil {
+			return err
+		}
+		a.index.Segments[j] = updated
+		if j == i {
+			report.Rescanned = append(report.Rescanned, updated)
+		}
+	}
+	return nil
+}
+
+// fetch returns the scrobbles made between from and to in chronological order,
+// excluding the track currently playing.
+func (a *Archive) fetch(from, to int64) (plays []user.RecentTrack, err error) {
+	for page := 1; ; page++ {
+		rt, err := a.user.GetRecentTracksRange(false, from, to, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, play := range rt.List() {
+			if !play.NowPlaying {
+				plays = append(plays, play)
+			}
+		}
+		if page >= rt.TotalPages() {
+			break
+		}
+	}
+	sort.SliceStable(plays, func(i, j int) bool {
+		return plays[i].Time.Before(plays[j].Time)
+	})
+	return
+}
+
+// playsAt returns the archived scrobbles made at the unixtime ts.
+func (a *Archive) playsAt(ts int64) ([]user.RecentTrack, error) {
+	n := len(a.index.Segments)
+	if n == 0 {
+		return nil, nil
+	}
+	list, err := a.readSegment(a.index.Segments[n-1])
+	if err != nil {
+		return nil, err
+	}
+	var plays []user.RecentTrack
+	for _, play := range list {
+		if play.Time.Unix() == ts {
+			plays = append(plays, play)
+		}
+	}
+	return plays, nil
+}
+
+// append adds plays to the newest segment, starting new segments once
+// SegmentSize is reached.
+func (a *Archive) append(plays []user.RecentTrack) (err error) {
+	for len(plays) > 0 {
+		n := len(a.index.Segments)
+		if n == 0 || (a.SegmentSize > 0 && a.index.Segments[n-1].Count >= a.SegmentSize) {
+			a.index.Segments = append(a.index.Segments, a.newSegment())
+			n++
+		}
+		seg := a.index.Segments[n-1]
+		count := a.SegmentSize - seg.Count
+		if a.SegmentSize <= 0 || count > len(plays) {
+			count = len(plays)
+		}
+		if a.index.Segments[n-1], err = a.appendSegment(seg, plays[:count]); err != nil {
+			return err
+		}
+		plays = plays[count:]
+	}
+	return nil
+}
+
+// withoutPlays returns plays without the entries present in existing.
+func withoutPlays(plays, existing []user.RecentTrack) (filtered []user.RecentTrack) {
+	seen := map[string]int{}
+	for _, play := range existing {
+		seen[playKey(play)]++
+	}
+	for _, play := range plays {
+		if key := playKey(play); seen[key] > 0 {
+			seen[key]--
+			continue
+		}
+		filtered = append(filtered, play)
+	}
+	return
+}
+
+func playKey(play user.RecentTrack) string {
+	return fmt.Sprintf("%d\x00%s\x00%s", play.Time.Unix(), strings.ToLower(play.Artist), strings.ToLower(play.Track))
+}
+
+// Open returns the Archive stored in dir for the provided user, creating it
+// when it does not exist yet.
+func Open(dir string, client *lastfm.Client, username string) (archive *Archive, err error) {
+	if err = os.MkdirAll(filepath.Join(dir, segmentsDir), 0755); err != nil {
+		return nil, err
+	}
+	archive = &Archive{
+		dir:          dir,
+		user:         user.New(client, username),
+		SegmentSize:  10000,
+		RescanWindow: 7 * 24 * time.Hour,
+	}
+	if err = archive.loadIndex(); err != nil {
+		return nil, err
+	}
+	if archive.index.Username == "" {
+		archive.index.Username = username
+	}
+	if !strings.EqualFold(archive.index.Username, username) {
+		return nil, fmt.Errorf("archive: %v belongs to user %v", dir, archive.index.Username)
+	}
+	err = archive.recover()
+	return
+}
+
+// recover updates the newest segment in the index with the scrobbles written
+// to its file, in case the index was not saved after the last write.
+func (a *Archive) recover() error {
+	n := len(a.index.Segments)
+	if n == 0 {
+		return nil
+	}
+	seg := a.index.Segments[n-1]
+	plays, err := a.readSegment(seg)
+	if os.IsNotExist(err) {
+		plays, err = nil, nil
+	}
+	if err != nil || len(plays) == seg.Count {
+		return err
+	}
+	seg.Count = len(plays)
+	if len(plays) > 0 {
+		seg.First, seg.Last = plays[0].Time.Unix(), plays[len(plays)-1].Time.Unix()
+	}
+	a.index.Segments[n-1] = seg
+	return a.saveIndex()
+}
@@ -0,0 +1,59 @@
+package archive
+
+import (
+	"sync"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+// Archive represents a local, file-based copy of the scrobble history of a
+// LastFM user.
+//
+// Scrobbles are stored in chronological order as JSON Lines in segment files,
+// and an index records the time range covered by each segment.
+type Archive struct {
+	dir   string
+	index index
+	mu    sync.Mutex
+	user  *user.User
+
+	// SegmentSize is the number of scrobbles after which a new segment
+	// file is started.
+	SegmentSize int
+	// RescanWindow is the period before the newest archived scrobble which is
+	// checked against LastFM on every Sync, to detect deleted or missing
+	// scrobbles.
+	RescanWindow time.Duration
+}
+
+// Segment is a file of the archive holding scrobbles played between First
+// and Last, in unixtime.
+type Segment struct {
+	File  string `json:"file"`
+	First int64  `json:"first"`
+	Last  int64  `json:"last"`
+	Count int    `json:"count"`
+}
+
+type index struct {
+	Username string    `json:"username"`
+	Segments []Segment `json:"segments"`
+	LastSync time.Time `json:"last_sync"`
+	NextFile int       `json:"next_file"`
+}
+
+// Query selects scrobbles from the archive. Zero values match all scrobbles.
+// Artist and Track are compared case-insensitively.
+type Query struct {
+	From   time.Time
+	To     time.Time
+	Artist string
+	Track  string
+}
+
+// SyncReport describes the changes made to the archive by Sync or Verify.
+type SyncReport struct {
+	Added     int
+	Rescanned []Segment
+}
@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+const (
+	indexFile   = "index.json"
+	segmentsDir = "segments"
+)
+
+func (a *Archive) loadIndex() error {
+	data, err := ioutil.ReadFile(filepath.Join(a.dir, indexFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &a.index)
+}
+
+func (a *Archive) saveIndex() error {
+	data, err := json.MarshalIndent(a.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(a.dir, indexFile)
+	if err = ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (a *Archive) segmentPath(seg Segment) string {
+	return filepath.Join(a.dir, segmentsDir, seg.File)
+}
+
+func (a *Archive) readSegment(seg Segment) (plays []user.RecentTrack, err error) {
+	f, err := os.Open(a.segmentPath(seg))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var play user.RecentTrack
+		if err = json.Unmarshal(scanner.Bytes(), &play); err != nil {
+			return nil, fmt.Errorf("%v: %v", seg.File, err)
+		}
+		plays = append(plays, play)
+	}
+	return plays, scanner.Err()
+}
+
+// appendSegment appends plays to the file of seg, and returns the updated segment.
+func (a *Archive) appendSegment(seg Segment, plays []user.RecentTrack) (Segment, error) {
+	f, err := os.OpenFile(a.segmentPath(seg), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return seg, err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, play := range plays {
+		if err = enc.Encode(play); err != nil {
+			f.Close()
+			return seg, err
+		}
+		if seg.Count == 0 {
+			seg.First = play.Time.Unix()
+		}
+		seg.Last = play.Time.Unix()
+		seg.Count++
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return seg, err
+	}
+	return seg, f.Close()
+}
+
+// rewriteSegment replaces the contents of the file of seg with plays.
+func (a *Archive) rewriteSegment(seg Segment, plays []user.RecentTrack) (Segment, error) {
+	tmp := Segment{File: seg.File + ".tmp"}
+	os.Remove(a.segmentPath(tmp))
+	tmp, err := a.appendSegment(tmp, plays)
+	if err != nil {
+		return seg, err
+	}
+	if err = os.Rename(a.segmentPath(tmp), a.segmentPath(seg)); err != nil {
+		return seg, err
+	}
+	tmp.File = seg.File
+	if tmp.Count == 0 {
+		tmp.First, tmp.Last = seg.First, seg.Last
+	}
+	return tmp, nil
+}
+
+func (a *Archive) newSegment() Segment {
+	a.index.NextFile++
+	return Segment{File: fmt.Sprintf("%08d.jsonl", a.index.NextFile)}
+}
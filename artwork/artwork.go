@@ -0,0 +1,142 @@
+// Package artwork selects, resizes and caches the images returned by the
+// LastFM API.
+package artwork
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/artist"
+)
+
+// placeholders are the image hashes LastFM serves when no artwork is available.
+var placeholders = []string{
+	"2a96cbd8b46e442fc41c2b86b821562f",
+	"c6f59c1e5e7240a4c0d427abd71f3dbb",
+}
+
+// cdnSize matches the size segment of LastFM CDN image URLs, such as
+// `/i/u/300x300/` or `/i/u/64s/`.
+var cdnSize = regexp.MustCompile(`/i/u/[^/]+/`)
+
+// Pixels returns the approximate width in pixels of images of size s,
+// or 0 for unknown sizes.
+func (s ImageSize) Pixels() int {
+	switch s {
+	case SizeSmall:
+		return 34
+	case SizeMedium:
+		return 64
+	case SizeLarge:
+		return 174
+	case SizeExtraLarge:
+		return 300
+	case SizeMega:
+		return 600
+	}
+	return 0
+}
+
+// FromModel converts an image list from any LastFM API response, such as
+// the Image field of artist.GetInfo, to a slice of Image. Values which are
+// not slices of structs with `Size` and `Text` string fields yield nil.
+func FromModel(list interface{}) (images []Image) {
+	value := reflect.ValueOf(list)
+	if value.Kind() != reflect.Slice {
+		return nil
+	}
+	for i := 0; i < value.Len(); i++ {
+		item := reflect.Indirect(value.Index(i))
+		if item.Kind() != reflect.Struct {
+			return nil
+		}
+		size, text := item.FieldByName("Size"), item.FieldByName("Text")
+		if size.Kind() != reflect.String || text.Kind() != reflect.String {
+			return nil
+		}
+		images = append(images, Image{Size: ImageSize(size.String()), URL: text.String()})
+	}
+	return
+}
+
+// IsPlaceholder reports whether url is empty or points to one of the
+// generic placeholder images served by LastFM.
+func IsPlaceholder(url string) bool {
+	if url == "" {
+		return true
+	}
+	for _, hash := range placeholders {
+		if strings.Contains(url, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// Best returns the smallest image at least target pixels wide, or the
+// largest image available if none is large enough. Placeholder images are
+// never returned.
+func Best(images []Image, target int) (best Image, ok bool) {
+	for _, image := range images {
+		if IsPlaceholder(image.URL) {
+			continue
+		}
+		px, bestPx := image.Size.Pixels(), best.Size.Pixels()
+		switch {
+		case !ok:
+		case bestPx >= target && (px < target || px >= bestPx):
+			continue
+		case bestPx < target && px <= bestPx:
+			continue
+		}
+		best, ok = image, true
+	}
+	return
+}
+
+// Resize rewrites a LastFM CDN image URL to request a square image of px
+// pixels. A px <= 0 requests the original image. URLs not served by the
+// LastFM CDN are returned unchanged.
+func Resize(url string, px int) string {
+	size := "ar0"
+	if px > 0 {
+		size = strconv.Itoa(px) + "x" + strconv.Itoa(px)
+	}
+	return cdnSize.ReplaceAllLiteralString(url, "/i/u/"+size+"/")
+}
+
+// ArtistImage returns the URL of the best image for the provided artist for
+// target pixels. When LastFM only has placeholders for the artist, the image
+// of the most popular album of the artist with artwork is used instead. An
+// empty URL is returned when no artwork is available.
+func (r *Resolver) ArtistImage(name string, target int) (url string, err error) {
+	ai, err := r.artist.GetInfo(name, "", "")
+	if err != nil {
+		return "", err
+	}
+	if image, ok := Best(FromModel(ai.Artist.Image), target); ok {
+		return image.URL, nil
+	}
+
+	ata, err := r.artist.GetTopAlbums(name, "", 1)
+	if err != nil {
+		return "", err
+	}
+	for _, album := range ata.TopAlbums.Album {
+		if image, ok := Best(FromModel(album.Image), target); ok {
+			return image.URL, nil
+		}
+	}
+	return "", nil
+}
+
+// New returns an instance of the artwork Resolver.
+func New(client *lastfm.Client, autocorrect bool) (resolver *Resolver) {
+	resolver = &Resolver{
+		artist: artist.New(client, "", autocorrect),
+	}
+	return
+}
@@ -0,0 +1,151 @@
+package artwork
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// downloadPrefix is the name prefix of images being downloaded.
+const downloadPrefix = "download-"
+
+// Fetch returns the path of the cached copy of the image at url, downloading
+// it first when it is not cached yet. The least recently used images are
+// removed once the cache grows past its size limit. Concurrent fetches of the
+// same url share a single download.
+func (c *Cache) Fetch(url string) (file string, err error) {
+	sum := sha1.Sum([]byte(url))
+	file = filepath.Join(c.dir, hex.EncodeToString(sum[:])+extension(url))
+
+	c.mu.Lock()
+	now := time.Now()
+	if _, err = os.Stat(file); err == nil {
+		c.mu.Unlock()
+		return file, os.Chtimes(file, now, now)
+	}
+	if d, ok := c.downloads[file]; ok {
+		c.mu.Unlock()
+		<-d.done
+		if d.err != nil {
+			return "", d.err
+		}
+		return file, nil
+	}
+	d := &download{done: make(chan struct{})}
+	c.downloads[file] = d
+	c.mu.Unlock()
+
+	d.err = c.download(url, file)
+
+	c.mu.Lock()
+	delete(c.downloads, file)
+	if d.err == nil {
+		d.err = c.evict(file)
+	}
+	c.mu.Unlock()
+	close(d.done)
+
+	if d.err != nil {
+		return "", d.err
+	}
+	return file, nil
+}
+
+// download saves the image at url to file.
+func (c *Cache) download(url, file string) error {
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("artwork: fetching %v: %v", url, resp.Status)
+	}
+
+	tmp, err := ioutil.TempFile(c.dir, downloadPrefix)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// extension returns the file extension of the path of rawurl, without its
+// query string or fragment.
+func extension(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return path.Ext(u.Path)
+}
+
+// evict removes the least recently used images until the cache fits within
+// its size limit. The file at keep is never removed.
+func (c *Cache) evict(keep string) error {
+	if c.maxBytes <= 0 {
+		return nil
+	}
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	var total int64
+	for _, f := range files {
+		total += f.Size()
+	}
+	for _, f := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		name := filepath.Join(c.dir, f.Name())
+		if f.IsDir() || name == keep || strings.HasPrefix(f.Name(), downloadPrefix) {
+			continue
+		}
+		if err = os.Remove(name); err != nil {
+			return err
+		}
+		total -= f.Size()
+	}
+	return nil
+}
+
+// NewCache returns a Cache storing images in dir, limited to maxBytes in total.
+// A maxBytes <= 0 leaves the cache unlimited.
+func NewCache(dir string, maxBytes int64) (cache *Cache, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	cache = &Cache{
+		dir:       dir,
+		downloads: make(map[string]*download),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		maxBytes: maxBytes,
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package artwork
+
+import (
+	"net/http"
+	"sync"
+
+	"git.maych.in/thunderbottom/lastfm-go/api/artist"
+)
+
+// ImageSize is a size name used by LastFM for images.
+type ImageSize string
+
+// Image sizes returned by the LastFM API.
+const (
+	SizeSmall      ImageSize = "small"
+	SizeMedium     ImageSize = "medium"
+	SizeLarge      ImageSize = "large"
+	SizeExtraLarge ImageSize = "extralarge"
+	SizeMega       ImageSize = "mega"
+)
+
+// Image is an image URL along with its size.
+type Image struct {
+	Size ImageSize
+	URL  string
+}
+
+// Resolver represents a structure to look up artwork for artists on LastFM,
+// falling back to album artwork when LastFM has no artist image.
+type Resolver struct {
+	artist *artist.Artist
+}
+
+// Cache is an on-disk cache of downloaded images, limited in total size.
+type Cache struct {
+	dir        string
+	downloads  map[string]*download
+	httpClient *http.Client
+	maxBytes   int64
+	mu         sync.Mutex
+}
+
+// download is an image download in progress, shared by concurrent fetches.
+type download struct {
+	done chan struct{}
+	err  error
+}
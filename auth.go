@@ -3,13 +3,20 @@ package lastfm
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/xml"
 	"net/url"
 	"sort"
 )
 
-func (client *Client) generateSignature(params url.Values) (signature string) {
+// Signature returns the LastFM API method signature for params, signed using
+// the provided API secret. The `format`, `callback` and `api_sig` parameters
+// are not part of the signature.
+func Signature(params url.Values, secret string) (signature string) {
 	keys := make([]string, 0, len(params))
 	for key := range params {
+		if key == "format" || key == "callback" || key == "api_sig" {
+			continue
+		}
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
@@ -18,7 +25,7 @@ func (client *Client) generateSignature(params url.Values) (signature string) {
 	for _, key := range keys {
 		sigParams += key + params.Get(key)
 	}
-	sigParams += client.APISecret
+	sigParams += secret
 
 	md5Hash := md5.New()
 	md5Hash.Write([]byte(sigParams))
@@ -51,6 +58,52 @@ func (client *Client) Login(username string, password string) (err error) {
 	return
 }
 
+// GetToken fetches an unauthorized request token from LastFM for the desktop
+// authentication flow. The user must authorize the token by visiting the URL
+// returned by AuthURL, before exchanging it for a session using GetSession.
+func (client *Client) GetToken() (token string, err error) {
+	var resp struct {
+		XMLName xml.Name `xml:"token"`
+		Token   string   `xml:",chardata"`
+	}
+	p := &Provider{
+		Method:   "auth.gettoken",
+		Params:   map[string]string{},
+		Response: &resp,
+		Type:     "POST",
+	}
+	err = client.Request(p)
+	token = resp.Token
+	return
+}
+
+// AuthURL returns the URL where the user authorizes the provided request token
+// for the Client, on the service the Client is bound to.
+func (client *Client) AuthURL(token string) string {
+	params := url.Values{}
+	params.Set("api_key", client.APIKey)
+	params.Set("token", token)
+	return client.service.AuthURL + "?" + params.Encode()
+}
+
+// GetSession creates a web service session for the LastFM user using a request
+// token authorized by the user, and sets the session key within the LastFM Client.
+func (client *Client) GetSession(token string) (auth *Auth, err error) {
+	client.sessionKey = ""
+	auth = &Auth{}
+	p := &Provider{
+		Method:   "auth.getsession",
+		Params:   map[string]string{"token": token},
+		Response: auth,
+		Type:     "POST",
+	}
+	if err = client.Request(p); err != nil {
+		return nil, err
+	}
+	client.sessionKey = auth.Key
+	return
+}
+
 // Logout clears the current web service session and logs the user out of LastFM
 func (client *Client) Logout() {
 	client.sessionKey = ""
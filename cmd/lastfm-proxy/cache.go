@@ -0,0 +1,75 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// cache is an in-memory LRU cache of response bodies, expiring entries
+// after a fixed TTL.
+type cache struct {
+	entries map[string]*list.Element
+	lru     *list.List
+	max     int
+	mu      sync.Mutex
+	ttl     time.Duration
+}
+
+type cacheEntry struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+func newCache(max int, ttl time.Duration) *cache {
+	return &cache{
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		max:     max,
+		ttl:     ttl,
+	}
+}
+
+func (c *cache) get(key string) (body []byte, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.body, true
+}
+
+func (c *cache) set(key string, body []byte) {
+	if c.max <= 0 || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &cacheEntry{key: key, body: body, expires: time.Now().Add(c.ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *cache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,64 @@
+// Command lastfm-proxy is a caching read-through proxy for the LastFM API.
+//
+// Services query the proxy like the LastFM API, without an API key, and
+// always with the JSON format:
+//
+//	GET http://localhost:8081/2.0/?method=artist.getinfo&artist=Cher&format=json
+//
+// The proxy adds its own API key, serves repeated requests from a cache,
+// coalesces identical requests in flight, and spreads upstream requests under
+// a global rate limit. Responses are the JSON returned by LastFM. Recent
+// tracks and user info change while users listen, and are never cached.
+//
+// Usage:
+//
+//	LASTFM_API_KEY=... LASTFM_API_SECRET=... lastfm-proxy -listen :8081
+//
+// Cache and upstream counters are served as JSON on /metrics.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:8081", "address to listen on")
+	ttl := flag.Duration("ttl", 10*time.Minute, "time responses are cached for")
+	entries := flag.Int("cache-size", 10000, "maximum number of cached responses")
+	rate := flag.Float64("rate", 5, "maximum upstream requests per second")
+	baseURL := flag.String("base-url", "", "upstream API URL (default: LastFM)")
+	flag.Parse()
+
+	apiKey, apiSecret := os.Getenv("LASTFM_API_KEY"), os.Getenv("LASTFM_API_SECRET")
+	if apiKey == "" {
+		log.Fatal("LASTFM_API_KEY must be set")
+	}
+
+	service := lastfm.LastFM
+	if *baseURL != "" {
+		service = lastfm.Service{Name: *baseURL, BaseURL: *baseURL}
+	}
+	client := lastfm.NewWithService(service, apiKey, apiSecret)
+	client.SetUserAgent("lastfm-proxy")
+	client.SetRateLimit(*rate)
+
+	p := newProxy(&client, newCache(*entries, *ttl))
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", http.HandlerFunc(p.serveMetrics))
+	mux.Handle("/", p)
+
+	log.Printf("lastfm-proxy listening on %v", *listen)
+	server := &http.Server{
+		Addr:         *listen,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	log.Fatal(server.ListenAndServe())
+}
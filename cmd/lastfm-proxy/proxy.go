@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// ignoredParams are not forwarded upstream, nor part of the cache key.
+var ignoredParams = map[string]bool{
+	"api_key":  true,
+	"api_sig":  true,
+	"callback": true,
+	"format":   true,
+	"method":   true,
+	"sk":       true,
+}
+
+// volatileMethods return data which changes while a user listens, and are
+// never served from the cache.
+var volatileMethods = map[string]bool{
+	"user.getinfo":         true,
+	"user.getrecenttracks": true,
+}
+
+// proxy serves the LastFM read methods using a shared Client.
+type proxy struct {
+	cache   *cache
+	client  *lastfm.Client
+	flights map[string]*flight
+	metrics metrics
+	mu      sync.Mutex
+}
+
+// flight is an upstream request shared by the identical requests made while
+// it is in progress.
+type flight struct {
+	done chan struct{}
+	body json.RawMessage
+	err  error
+}
+
+// metrics are the counters served on /metrics.
+type metrics struct {
+	Requests       int64 `json:"requests"`
+	Rejected       int64 `json:"rejected"`
+	CacheHits      int64 `json:"cache_hits"`
+	CacheMisses    int64 `json:"cache_misses"`
+	CacheEntries   int64 `json:"cache_entries"`
+	Coalesced      int64 `json:"coalesced_requests"`
+	Upstream       int64 `json:"upstream_requests"`
+	UpstreamErrors int64 `json:"upstream_errors"`
+	UpstreamMillis int64 `json:"upstream_duration_ms"`
+}
+
+func newProxy(client *lastfm.Client, c *cache) *proxy {
+	return &proxy{cache: c, client: client, flights: make(map[string]*flight)}
+}
+
+// readMethod reports whether method only reads data from LastFM.
+func readMethod(method string) bool {
+	parts := strings.SplitN(strings.ToLower(method), ".", 2)
+	if len(parts) != 2 || parts[0] == "auth" {
+		return false
+	}
+	return strings.HasPrefix(parts[1], "get") || parts[1] == "search"
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&p.metrics.Requests, 1)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		atomic.AddInt64(&p.metrics.Rejected, 1)
+		writeError(w, http.StatusMethodNotAllowed, 3, "Invalid Method - The proxy only supports GET requests")
+		return
+	}
+	query := r.URL.Query()
+	method := strings.ToLower(query.Get("method"))
+	if !readMethod(method) {
+		atomic.AddInt64(&p.metrics.Rejected, 1)
+		writeError(w, http.StatusBadRequest, 3, "Invalid Method - No method with that name in this package")
+		return
+	}
+	// Responses are always the JSON returned by LastFM, so requests for its
+	// default XML format or for JSONP are rejected.
+	if query.Get("format") != "json" || query.Get("callback") != "" {
+		atomic.AddInt64(&p.metrics.Rejected, 1)
+		writeError(w, http.StatusBadRequest, 6, "Invalid parameters - The proxy only supports format=json without a callback")
+		return
+	}
+
+	params := map[string]string{}
+	for key := range query {
+		if !ignoredParams[key] {
+			params[key] = query.Get(key)
+		}
+	}
+	key := cacheKey(method, params)
+	if body, ok := p.cache.get(key); ok {
+		atomic.AddInt64(&p.metrics.CacheHits, 1)
+		writeBody(w, "HIT", body)
+		return
+	}
+	atomic.AddInt64(&p.metrics.CacheMisses, 1)
+
+	body, err := p.fetch(r.Context(), key, method, params)
+	if err != nil {
+		var apiErr *lastfm.APIError
+		if errors.As(err, &apiErr) {
+			writeError(w, errorStatus(apiErr.Code), apiErr.Code, apiErr.Message)
+			return
+		}
+		writeError(w, http.StatusBadGateway, 16, "There was a temporary error processing your request. Please try again")
+		return
+	}
+	writeBody(w, "MISS", body)
+}
+
+// fetch returns the upstream response for the request, joining the identical
+// request in progress if there is one. The upstream request keeps running
+// for the other callers when ctx is done.
+func (p *proxy) fetch(ctx context.Context, key, method string, params map[string]string) (json.RawMessage, error) {
+	p.mu.Lock()
+	f, ok := p.flights[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		p.flights[key] = f
+		go p.upstream(key, f, method, params)
+	} else {
+		atomic.AddInt64(&p.metrics.Coalesced, 1)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.body, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// upstream makes the request of the flight to LastFM, and caches a
+// successful response unless the method is volatile.
+func (p *proxy) upstream(key string, f *flight, method string, params map[string]string) {
+	provider := &lastfm.Provider{
+		Method:   method,
+		Params:   params,
+		Response: &f.body,
+		Type:     "GET",
+	}
+	start := time.Now()
+	f.err = p.client.Request(provider)
+	atomic.AddInt64(&p.metrics.Upstream, 1)
+	atomic.AddInt64(&p.metrics.UpstreamMillis, int64(time.Since(start)/time.Millisecond))
+	if f.err != nil {
+		atomic.AddInt64(&p.metrics.UpstreamErrors, 1)
+	} else if !volatileMethods[method] {
+		p.cache.set(key, f.body)
+	}
+
+	p.mu.Lock()
+	delete(p.flights, key)
+	p.mu.Unlock()
+	close(f.done)
+}
+
+func (p *proxy) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	m := metrics{
+		Requests:       atomic.LoadInt64(&p.metrics.Requests),
+		Rejected:       atomic.LoadInt64(&p.metrics.Rejected),
+		CacheHits:      atomic.LoadInt64(&p.metrics.CacheHits),
+		CacheMisses:    atomic.LoadInt64(&p.metrics.CacheMisses),
+		CacheEntries:   int64(p.cache.len()),
+		Coalesced:      atomic.LoadInt64(&p.metrics.Coalesced),
+		Upstream:       atomic.LoadInt64(&p.metrics.Upstream),
+		UpstreamErrors: atomic.LoadInt64(&p.metrics.UpstreamErrors),
+		UpstreamMillis: atomic.LoadInt64(&p.metrics.UpstreamMillis),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// cacheKey returns the key of a request, independent of parameter order.
+func cacheKey(method string, params map[string]string) string {
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	return method + "?" + values.Encode()
+}
+
+// errorStatus maps a LastFM error code to the HTTP status of the response.
+func errorStatus(code int) int {
+	switch code {
+	case 4, 9, 10, 14, 15, 17, 26:
+		return http.StatusForbidden
+	case 29:
+		return http.StatusTooManyRequests
+	case 8, 11, 16:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+func writeBody(w http.ResponseWriter, cacheStatus string, body []byte) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Cache", cacheStatus)
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": code, "message": message})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// upstream is a stand-in LastFM API counting its requests. Requests for an
+// artist named "error N" fail with the LastFM error code N.
+type upstream struct {
+	requests int64
+	// release, if set, holds the responses until it is closed.
+	release chan struct{}
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&u.requests, 1)
+	if u.release != nil {
+		<-u.release
+	}
+	w.Header().Set("Content-Type", "application/json")
+	artist := r.URL.Query().Get("artist")
+	if strings.HasPrefix(artist, "error ") {
+		code, _ := strconv.Atoi(strings.TrimPrefix(artist, "error "))
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":%d,"message":"failed with %d"}`, code, code)
+		return
+	}
+	fmt.Fprintf(w, `{"artist":{"name":%q}}`, artist)
+}
+
+func newTestProxy(t *testing.T) (*proxy, *upstream) {
+	u := &upstream{}
+	srv := httptest.NewServer(u)
+	t.Cleanup(srv.Close)
+	client := lastfm.NewWithService(lastfm.Service{Name: "stub", BaseURL: srv.URL}, "key", "secret")
+	return newProxy(&client, newCache(100, time.Minute)), u
+}
+
+func get(p *proxy, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/2.0/?"+query, nil))
+	return w
+}
+
+func TestCache(t *testing.T) {
+	p, u := newTestProxy(t)
+
+	first := get(p, "method=artist.getInfo&artist=Cher&format=json")
+	if first.Code != http.StatusOK || first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("got %v %v", first.Code, first.Header().Get("X-Cache"))
+	}
+	// The parameter order and the client's API key do not change the key.
+	second := get(p, "format=json&api_key=other&artist=Cher&method=artist.getinfo")
+	if second.Code != http.StatusOK || second.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("got %v %v", second.Code, second.Header().Get("X-Cache"))
+	}
+	if first.Body.String() != `{"artist":{"name":"Cher"}}` || second.Body.String() != first.Body.String() {
+		t.Errorf("got bodies %q and %q", first.Body, second.Body)
+	}
+	if u.requests != 1 {
+		t.Errorf("got %d upstream requests, want 1", u.requests)
+	}
+
+	// Recent tracks are never cached.
+	for i := 0; i < 2; i++ {
+		if w := get(p, "method=user.getRecentTracks&user=alice&format=json"); w.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("got %v for recent tracks", w.Header().Get("X-Cache"))
+		}
+	}
+	if u.requests != 3 {
+		t.Errorf("got %d upstream requests, want 3", u.requests)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	p, u := newTestProxy(t)
+	u.release = make(chan struct{})
+
+	const n = 10
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = get(p, "method=artist.getinfo&artist=Cher&format=json").Body.String()
+		}(i)
+	}
+	// Release the upstream request once every other request joined it.
+	for atomic.LoadInt64(&p.metrics.Coalesced) < n-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(u.release)
+	wg.Wait()
+
+	if u.requests != 1 {
+		t.Errorf("got %d upstream requests for %d concurrent misses, want 1", u.requests, n)
+	}
+	for i, body := range bodies {
+		if body != `{"artist":{"name":"Cher"}}` {
+			t.Errorf("request %d: got %q", i, body)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	p, _ := newTestProxy(t)
+	tests := []struct {
+		method string
+		query  string
+		status int
+		code   int
+	}{
+		{http.MethodPost, "method=artist.getinfo&artist=Cher&format=json", http.StatusMethodNotAllowed, 3},
+		{http.MethodGet, "method=track.scrobble&artist=Cher&format=json", http.StatusBadRequest, 3},
+		{http.MethodGet, "method=auth.getsession&token=t&format=json", http.StatusBadRequest, 3},
+		{http.MethodGet, "method=artist.getinfo&artist=Cher", http.StatusBadRequest, 6},
+		{http.MethodGet, "method=artist.getinfo&artist=Cher&format=xml", http.StatusBadRequest, 6},
+		{http.MethodGet, "method=artist.getinfo&artist=Cher&format=json&callback=f", http.StatusBadRequest, 6},
+		{http.MethodGet, "method=artist.getinfo&artist=error+6&format=json", http.StatusBadRequest, 6},
+		{http.MethodGet, "method=artist.getinfo&artist=error+26&format=json", http.StatusForbidden, 26},
+		{http.MethodGet, "method=artist.getinfo&artist=error+29&format=json", http.StatusTooManyRequests, 29},
+		{http.MethodGet, "method=artist.getinfo&artist=error+16&format=json", http.StatusServiceUnavailable, 16},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(test.method, "/2.0/?"+test.query, nil))
+		var resp struct {
+			Error int `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%v: %v", test.query, err)
+			continue
+		}
+		if w.Code != test.status || resp.Error != test.code {
+			t.Errorf("%v %v: got %v with error %v, want %v with %v", test.method, test.query, w.Code, resp.Error, test.status, test.code)
+		}
+	}
+
+	// Failed requests are not cached.
+	if w := get(p, "method=artist.getinfo&artist=error+16&format=json"); w.Header().Get("X-Cache") == "HIT" {
+		t.Error("failed response was cached")
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	client := lastfm.NewWithService(lastfm.Service{Name: "stub", BaseURL: srv.URL}, "key", "secret")
+	p := newProxy(&client, newCache(100, time.Minute))
+
+	if w := get(p, "method=artist.getinfo&artist=Cher&format=json"); w.Code != http.StatusBadGateway {
+		t.Errorf("got status %v for an unreachable upstream, want %v", w.Code, http.StatusBadGateway)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	p, _ := newTestProxy(t)
+	get(p, "method=artist.getinfo&artist=Cher&format=json")
+	get(p, "method=artist.getinfo&artist=Cher&format=json")
+	get(p, "method=artist.getinfo&artist=error+6&format=json")
+	get(p, "method=track.love&artist=Cher&format=json")
+
+	w := httptest.NewRecorder()
+	p.serveMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var m metrics
+	if err := json.Unmarshal(w.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	want := metrics{
+		Requests:       4,
+		Rejected:       1,
+		CacheHits:      1,
+		CacheMisses:    2,
+		CacheEntries:   1,
+		Upstream:       2,
+		UpstreamErrors: 1,
+	}
+	m.UpstreamMillis = 0
+	if m != want {
+		t.Errorf("got metrics %+v, want %+v", m, want)
+	}
+}
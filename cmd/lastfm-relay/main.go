@@ -0,0 +1,98 @@
+// Command lastfm-relay is a local stand-in for the LastFM 2.0 scrobbling API.
+//
+// Devices are configured to use the relay as their LastFM endpoint, with the
+// API key and secret of the relay. Scrobbles and loved tracks are queued on
+// disk and forwarded to LastFM using the session key stored for each user,
+// so submissions survive connectivity loss and restarts.
+//
+// Usage:
+//
+//	lastfm-relay -config relay.json
+//
+// The relay implements auth.getMobileSession, track.scrobble,
+// track.updateNowPlaying and track.love.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// config is the JSON configuration of the relay.
+type config struct {
+	Listen string `json:"listen"`
+	// APIKey and APISecret are the credentials devices use with the relay.
+	APIKey    string `json:"api_key"`
+	APISecret string `json:"api_secret"`
+	// Upstream are the LastFM API credentials used to forward submissions.
+	// BaseURL defaults to the Last.fm API.
+	Upstream struct {
+		APIKey    string `json:"api_key"`
+		APISecret string `json:"api_secret"`
+		BaseURL   string `json:"base_url,omitempty"`
+	} `json:"upstream"`
+	DataDir string `json:"data_dir"`
+	// RetryInterval is the time between attempts to forward the queue,
+	// as a Go duration string. Defaults to 30s.
+	RetryInterval string `json:"retry_interval"`
+	// Users maps LastFM user names to the password devices log in with,
+	// and the LastFM session key used upstream.
+	Users map[string]struct {
+		Password   string `json:"password"`
+		SessionKey string `json:"session_key"`
+	} `json:"users"`
+}
+
+func loadConfig(path string) (cfg *config, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg = &config{Listen: "127.0.0.1:8080", DataDir: "lastfm-relay", RetryInterval: "30s"}
+	if err = json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	if cfg.APIKey == "" || cfg.APISecret == "" || cfg.Upstream.APIKey == "" || cfg.Upstream.APISecret == "" {
+		return nil, fmt.Errorf("%v: api_key, api_secret and upstream credentials are required", path)
+	}
+	return cfg, nil
+}
+
+func main() {
+	configFile := flag.String("config", "relay.json", "path to the relay configuration")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	interval, err := time.ParseDuration(cfg.RetryInterval)
+	if err != nil {
+		log.Fatalf("invalid retry_interval: %v", err)
+	}
+
+	q, err := openQueue(cfg.DataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := newRelay(cfg, q)
+	go r.forward(interval)
+
+	log.Printf("lastfm-relay listening on %v", cfg.Listen)
+	server := &http.Server{
+		Addr:         cfg.Listen,
+		Handler:      r,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	if err = server.ListenAndServe(); err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// Kinds of queued submissions.
+const (
+	kindScrobble = "scrobble"
+	kindLove     = "love"
+)
+
+// submission is a queued request to be forwarded upstream.
+type submission struct {
+	ID        string            `json:"id"`
+	User      string            `json:"user"`
+	Kind      string            `json:"kind"`
+	Scrobbles []lastfm.Scrobble `json:"scrobbles,omitempty"`
+	Artist    string            `json:"artist,omitempty"`
+	Track     string            `json:"track,omitempty"`
+	Created   time.Time         `json:"created"`
+}
+
+// queue is a durable queue storing each submission in its own file, named so
+// that files sort in submission order.
+type queue struct {
+	dir    string
+	failed string
+	mu     sync.Mutex
+	seq    uint64
+}
+
+func openQueue(dataDir string) (q *queue, err error) {
+	q = &queue{
+		dir:    filepath.Join(dataDir, "queue"),
+		failed: filepath.Join(dataDir, "failed"),
+	}
+	for _, dir := range []string{q.dir, q.failed} {
+		if err = os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// push writes the submission to disk, and returns once it is synced.
+func (q *queue) push(s *submission) error {
+	q.mu.Lock()
+	q.seq++
+	seq := q.seq
+	q.mu.Unlock()
+
+	random := make([]byte, 4)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	s.Created = time.Now().UTC()
+	s.ID = fmt.Sprintf("%020d-%06d-%v", s.Created.UnixNano(), seq, hex.EncodeToString(random))
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(q.dir, "."+s.ID+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(q.dir, s.ID+".json"))
+}
+
+// pending returns the queued submissions in submission order. Files which can
+// not be read are moved out of the queue.
+func (q *queue) pending() (list []*submission, err error) {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".json") && !strings.HasPrefix(f.Name(), ".") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := &submission{}
+		data, err := ioutil.ReadFile(filepath.Join(q.dir, name))
+		if err == nil {
+			err = json.Unmarshal(data, s)
+		}
+		if err != nil {
+			log.Printf("moving unreadable submission %v out of the queue: %v", name, err)
+			if err = os.Rename(filepath.Join(q.dir, name), filepath.Join(q.failed, name)); err != nil {
+				log.Printf("moving %v: %v", name, err)
+			}
+			continue
+		}
+		s.ID = strings.TrimSuffix(name, ".json")
+		list = append(list, s)
+	}
+	return
+}
+
+// done removes a forwarded submission from the queue.
+func (q *queue) done(s *submission) error {
+	return os.Remove(filepath.Join(q.dir, s.ID+".json"))
+}
+
+// fail moves a submission which cannot be forwarded out of the queue.
+func (q *queue) fail(s *submission) error {
+	return os.Rename(filepath.Join(q.dir, s.ID+".json"), filepath.Join(q.failed, s.ID+".json"))
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+// LastFM error codes returned by the relay.
+const (
+	errInvalidMethod     = 3
+	errAuthFailed        = 4
+	errInvalidParameters = 6
+	errOperationFailed   = 8
+	errInvalidSession    = 9
+	errInvalidAPIKey     = 10
+	errInvalidSignature  = 13
+)
+
+// Errors of submissions the relay can never forward.
+var (
+	errUnknownUser = errors.New("user is not configured")
+	errUnknownKind = errors.New("unknown submission kind")
+)
+
+// relay serves the LastFM 2.0 API methods used by scrobbling devices.
+type relay struct {
+	cfg      *config
+	queue    *queue
+	mu       sync.Mutex
+	clients  map[string]*lastfm.Client
+	sessions map[string]string
+}
+
+func newRelay(cfg *config, q *queue) *relay {
+	r := &relay{
+		cfg:      cfg,
+		queue:    q,
+		clients:  map[string]*lastfm.Client{},
+		sessions: map[string]string{},
+	}
+	for name := range cfg.Users {
+		r.sessions[r.sessionKey(name)] = name
+	}
+	return r
+}
+
+// sessionKey derives the relay session key of a user from the relay secret,
+// so sessions survive restarts without being stored.
+func (r *relay) sessionKey(username string) string {
+	mac := hmac.New(sha256.New, []byte(r.cfg.APISecret))
+	mac.Write([]byte(strings.ToLower(username)))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// upstream returns the LastFM client forwarding the submissions of a user.
+func (r *relay) upstream(username string) *lastfm.Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[username]
+	if !ok {
+		service := lastfm.LastFM
+		if r.cfg.Upstream.BaseURL != "" {
+			service = lastfm.Service{Name: r.cfg.Upstream.BaseURL, BaseURL: r.cfg.Upstream.BaseURL}
+		}
+		c := lastfm.NewWithService(service, r.cfg.Upstream.APIKey, r.cfg.Upstream.APISecret)
+		c.SetUserAgent("lastfm-relay")
+		c.SetSessionKey(r.cfg.Users[username].SessionKey)
+		client = &c
+		r.clients[username] = client
+	}
+	return client
+}
+
+func (r *relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		writeError(w, req, http.StatusBadRequest, errInvalidParameters, "Invalid parameters")
+		return
+	}
+	params := req.Form
+	if params.Get("api_key") != r.cfg.APIKey {
+		writeError(w, req, http.StatusForbidden, errInvalidAPIKey, "Invalid API key - You must be granted a valid key by last.fm")
+		return
+	}
+	if req.Method != http.MethodPost {
+		writeError(w, req, http.StatusMethodNotAllowed, errInvalidMethod, "Invalid Method - No method with that name in this package")
+		return
+	}
+	expected := lastfm.Signature(params, r.cfg.APISecret)
+	if subtle.ConstantTimeCompare([]byte(strings.ToLower(params.Get("api_sig"))), []byte(expected)) != 1 {
+		writeError(w, req, http.StatusForbidden, errInvalidSignature, "Invalid method signature supplied")
+		return
+	}
+
+	method := strings.ToLower(params.Get("method"))
+	if method == "auth.getmobilesession" {
+		r.getMobileSession(w, req)
+		return
+	}
+
+	username, ok := r.sessions[params.Get("sk")]
+	if !ok {
+		writeError(w, req, http.StatusForbidden, errInvalidSession, "Invalid session key - Please re-authenticate")
+		return
+	}
+	switch method {
+	case "track.scrobble":
+		r.scrobble(w, req, username)
+	case "track.updatenowplaying":
+		r.nowPlaying(w, req, username)
+	case "track.love":
+		r.love(w, req, username)
+	default:
+		writeError(w, req, http.StatusBadRequest, errInvalidMethod, "Invalid Method - No method with that name in this package")
+	}
+}
+
+func (r *relay) getMobileSession(w http.ResponseWriter, req *http.Request) {
+	username, password := req.Form.Get("username"), req.Form.Get("password")
+	for name, user := range r.cfg.Users {
+		if !strings.EqualFold(name, username) {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) == 1 {
+			writeResponse(w, req, sessionResponse{Name: name, Key: r.sessionKey(name)})
+			return
+		}
+	}
+	writeError(w, req, http.StatusForbidden, errAuthFailed, "Authentication Failed - You do not have permissions to access the service")
+}
+
+func (r *relay) scrobble(w http.ResponseWriter, req *http.Request, username string) {
+	scrobbles, err := parseScrobbles(req.Form)
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, errInvalidParameters, err.Error())
+		return
+	}
+	if err = r.queue.push(&submission{User: username, Kind: kindScrobble, Scrobbles: scrobbles}); err != nil {
+		log.Printf("queueing scrobbles for %v: %v", username, err)
+		writeError(w, req, http.StatusServiceUnavailable, errOperationFailed, "Operation failed - Most likely the backend service failed. Please try again.")
+		return
+	}
+	writeResponse(w, req, newScrobblesResponse(scrobbles))
+}
+
+func (r *relay) nowPlaying(w http.ResponseWriter, req *http.Request, username string) {
+	scrobble := lastfm.Scrobble{
+		Artist:      req.Form.Get("artist"),
+		Track:       req.Form.Get("track"),
+		Album:       req.Form.Get("album"),
+		AlbumArtist: req.Form.Get("albumArtist"),
+		MBID:        req.Form.Get("mbid"),
+	}
+	scrobble.Duration, _ = strconv.ParseInt(req.Form.Get("duration"), 10, 64)
+	scrobble.TrackNumber, _ = strconv.Atoi(req.Form.Get("trackNumber"))
+	if scrobble.Artist == "" || scrobble.Track == "" {
+		writeError(w, req, http.StatusBadRequest, errInvalidParameters, "Invalid parameters - artist and track are required")
+		return
+	}
+
+	// Now playing updates are only useful while the track plays, so they are
+	// forwarded right away instead of being queued.
+	go func() {
+		if _, err := track.New(r.upstream(username), username, false).UpdateNowPlaying(scrobble); err != nil {
+			log.Printf("forwarding now playing for %v: %v", username, err)
+		}
+	}()
+	writeResponse(w, req, newNowPlayingResponse(scrobble))
+}
+
+func (r *relay) love(w http.ResponseWriter, req *http.Request, username string) {
+	s := &submission{User: username, Kind: kindLove, Artist: req.Form.Get("artist"), Track: req.Form.Get("track")}
+	if s.Artist == "" || s.Track == "" {
+		writeError(w, req, http.StatusBadRequest, errInvalidParameters, "Invalid parameters - artist and track are required")
+		return
+	}
+	if err := r.queue.push(s); err != nil {
+		log.Printf("queueing love for %v: %v", username, err)
+		writeError(w, req, http.StatusServiceUnavailable, errOperationFailed, "Operation failed - Most likely the backend service failed. Please try again.")
+		return
+	}
+	writeResponse(w, req, nil)
+}
+
+// forward sends the queued submissions upstream every interval.
+func (r *relay) forward(interval time.Duration) {
+	for {
+		r.forwardPending()
+		time.Sleep(interval)
+	}
+}
+
+// forwardPending sends the queued submissions upstream. The submissions of a
+// user are forwarded in order, and those of a user whose submission failed
+// temporarily, such as with a revoked session key, are held back until the
+// next attempt without holding up other users.
+func (r *relay) forwardPending() {
+	pending, err := r.queue.pending()
+	if err != nil {
+		log.Printf("reading queue: %v", err)
+	}
+	held := map[string]bool{}
+	for _, s := range pending {
+		if held[s.User] {
+			continue
+		}
+		err = r.submit(s)
+		if err == nil {
+			err = r.queue.done(s)
+		} else if permanent(err) {
+			log.Printf("dropping submission %v for %v: %v", s.ID, s.User, err)
+			err = r.queue.fail(s)
+		}
+		if err != nil {
+			log.Printf("forwarding submission %v for %v: %v", s.ID, s.User, err)
+			held[s.User] = true
+		}
+	}
+}
+
+func (r *relay) submit(s *submission) error {
+	if _, ok := r.cfg.Users[s.User]; !ok {
+		return fmt.Errorf("%v: %w", s.User, errUnknownUser)
+	}
+	t := track.New(r.upstream(s.User), s.User, false)
+	switch s.Kind {
+	case kindScrobble:
+		_, err := t.ScrobbleAll(s.Scrobbles)
+		return err
+	case kindLove:
+		return t.Love(s.Artist, s.Track)
+	}
+	return fmt.Errorf("%q: %w", s.Kind, errUnknownKind)
+}
+
+// permanent reports whether forwarding failed in a way retrying cannot fix:
+// the submission can not be forwarded at all, or LastFM rejected its
+// parameters. Network failures and other API errors, including invalid
+// session and suspended API key errors, are retried.
+func permanent(err error) bool {
+	if errors.Is(err, errUnknownUser) || errors.Is(err, errUnknownKind) {
+		return true
+	}
+	var apiErr *lastfm.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case 2, 3, 4, 5, 6, 7, 13:
+		return true
+	}
+	return false
+}
+
+// parseScrobbles reads the scrobbles of a track.scrobble request, either as
+// indexed array parameters such as `artist[0]`, or as plain parameters.
+func parseScrobbles(params map[string][]string) (scrobbles []lastfm.Scrobble, err error) {
+	get := func(name string, idx int) string {
+		key := name
+		if idx >= 0 {
+			key = name + "[" + strconv.Itoa(idx) + "]"
+		}
+		if values := params[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	indices := []int{-1}
+	if get("artist", -1) == "" {
+		indices = nil
+		for idx := 0; idx <= lastfm.MaxScrobbleBatch; idx++ {
+			if get("artist", idx) != "" {
+				indices = append(indices, idx)
+			}
+		}
+	}
+	for _, idx := range indices {
+		s := lastfm.Scrobble{
+			Artist:       get("artist", idx),
+			Track:        get("track", idx),
+			Album:        get("album", idx),
+			AlbumArtist:  get("albumArtist", idx),
+			Context:      get("context", idx),
+			StreamID:     get("streamId", idx),
+			MBID:         get("mbid", idx),
+			ChosenByUser: get("chosenByUser", idx) != "0",
+		}
+		s.Timestamp, _ = strconv.ParseInt(get("timestamp", idx), 10, 64)
+		s.Duration, _ = strconv.ParseInt(get("duration", idx), 10, 64)
+		s.TrackNumber, _ = strconv.Atoi(get("trackNumber", idx))
+		if s.Artist == "" || s.Track == "" || s.Timestamp <= 0 {
+			return nil, fmt.Errorf("Invalid parameters - artist, track and timestamp are required")
+		}
+		scrobbles = append(scrobbles, s)
+	}
+	if len(scrobbles) == 0 {
+		return nil, fmt.Errorf("Invalid parameters - no scrobbles found")
+	}
+	return
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// upstream is a stand-in LastFM API, failing the requests of a session key
+// or for a track with the configured error codes.
+type upstream struct {
+	mu       sync.Mutex
+	requests []url.Values
+	errors   map[string]int
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	u.mu.Lock()
+	u.requests = append(u.requests, params)
+	code, ok := u.errors[params.Get("sk")]
+	if !ok {
+		code, ok = u.errors[params.Get("track[1]")+params.Get("track")]
+	}
+	u.mu.Unlock()
+
+	if ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":%d,"message":"failed"}`, code)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	body := ""
+	if params.Get("method") == "track.scrobble" {
+		body = `<scrobbles accepted="1" ignored="0"><scrobble><track corrected="0">T</track><artist corrected="0">A</artist><timestamp>1</timestamp><ignoredMessage code="0"></ignoredMessage></scrobble></scrobbles>`
+	}
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><lfm status="ok">`+body+`</lfm>`)
+}
+
+// tracks returns the tracks of the forwarded requests.
+func (u *upstream) tracks() (tracks []string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, params := range u.requests {
+		tracks = append(tracks, params.Get("track[1]")+params.Get("track"))
+	}
+	return
+}
+
+func newTestRelay(t *testing.T) (*relay, *upstream) {
+	dir, err := ioutil.TempDir("", "lastfm-relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	q, err := openQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := &upstream{errors: map[string]int{}}
+	srv := httptest.NewServer(u)
+	t.Cleanup(srv.Close)
+
+	cfg := &config{APIKey: "relay-key", APISecret: "relay-secret"}
+	cfg.Upstream.APIKey = "key"
+	cfg.Upstream.APISecret = "secret"
+	cfg.Upstream.BaseURL = srv.URL
+	cfg.Users = map[string]struct {
+		Password   string `json:"password"`
+		SessionKey string `json:"session_key"`
+	}{
+		"alice": {Password: "alice-password", SessionKey: "sk-alice"},
+		"bob":   {Password: "bob-password", SessionKey: "sk-bob"},
+	}
+	return newRelay(cfg, q), u
+}
+
+// post sends the request to the relay, signed using secret, and decodes the
+// JSON response into v.
+func post(t *testing.T, r *relay, params url.Values, secret string, v interface{}) int {
+	params.Set("api_key", r.cfg.APIKey)
+	params.Set("format", "json")
+	params.Set("api_sig", lastfm.Signature(params, secret))
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	return w.Code
+}
+
+type errorResponse struct {
+	Error int `json:"error"`
+}
+
+func TestSignature(t *testing.T) {
+	r, _ := newTestRelay(t)
+	scrobble := func() url.Values {
+		return url.Values{
+			"method":    {"track.scrobble"},
+			"sk":        {r.sessionKey("alice")},
+			"artist":    {"Artist"},
+			"track":     {"Track"},
+			"timestamp": {"1600000000"},
+		}
+	}
+
+	var resp struct {
+		Scrobbles struct {
+			Attr struct {
+				Accepted int `json:"accepted"`
+			} `json:"@attr"`
+		} `json:"scrobbles"`
+	}
+	if code := post(t, r, scrobble(), "relay-secret", &resp); code != http.StatusOK || resp.Scrobbles.Attr.Accepted != 1 {
+		t.Fatalf("got status %v and %+v for a signed request", code, resp)
+	}
+
+	var errResp errorResponse
+	if code := post(t, r, scrobble(), "wrong-secret", &errResp); code != http.StatusForbidden || errResp.Error != errInvalidSignature {
+		t.Errorf("got status %v and error %v for a wrongly signed request", code, errResp.Error)
+	}
+	params := scrobble()
+	params.Set("sk", "unknown")
+	if code := post(t, r, params, "relay-secret", &errResp); code != http.StatusForbidden || errResp.Error != errInvalidSession {
+		t.Errorf("got status %v and error %v for an unknown session", code, errResp.Error)
+	}
+
+	pending, err := r.queue.pending()
+	if err != nil || len(pending) != 1 || pending[0].User != "alice" {
+		t.Fatalf("got pending %+v, %v, want the signed scrobble", pending, err)
+	}
+}
+
+func TestGetMobileSession(t *testing.T) {
+	r, _ := newTestRelay(t)
+
+	var resp struct {
+		Session sessionResponse `json:"session"`
+	}
+	params := url.Values{"method": {"auth.getMobileSession"}, "username": {"Alice"}, "password": {"alice-password"}}
+	if code := post(t, r, params, "relay-secret", &resp); code != http.StatusOK {
+		t.Fatalf("got status %v", code)
+	}
+	if resp.Session.Name != "alice" || resp.Session.Key != r.sessionKey("alice") {
+		t.Errorf("got session %+v", resp.Session)
+	}
+
+	var errResp errorResponse
+	params = url.Values{"method": {"auth.getMobileSession"}, "username": {"alice"}, "password": {"bob-password"}}
+	if code := post(t, r, params, "relay-secret", &errResp); code != http.StatusForbidden || errResp.Error != errAuthFailed {
+		t.Errorf("got status %v and error %v for a wrong password", code, errResp.Error)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	r, _ := newTestRelay(t)
+	q := r.queue
+
+	var pushed []*submission
+	for _, track := range []string{"One", "Two", "Three"} {
+		s := &submission{User: "alice", Kind: kindLove, Artist: "Artist", Track: track}
+		if err := q.push(s); err != nil {
+			t.Fatal(err)
+		}
+		pushed = append(pushed, s)
+	}
+	if err := ioutil.WriteFile(filepath.Join(q.dir, "00000000000000000000-000000-corrupt.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := q.pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := trackNames(pending); got != "One,Two,Three" {
+		t.Fatalf("got pending %v", got)
+	}
+
+	if err = q.done(pushed[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.fail(pushed[1]); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err = q.pending(); err != nil || trackNames(pending) != "Three" {
+		t.Fatalf("got pending %v, %v", trackNames(pending), err)
+	}
+
+	failed, err := ioutil.ReadDir(q.failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range failed {
+		names = append(names, f.Name())
+	}
+	if len(names) != 2 || names[0] != "00000000000000000000-000000-corrupt.json" || names[1] != pushed[1].ID+".json" {
+		t.Errorf("got failed submissions %v", names)
+	}
+}
+
+func trackNames(list []*submission) string {
+	var tracks []string
+	for _, s := range list {
+		tracks = append(tracks, s.Track)
+	}
+	return strings.Join(tracks, ",")
+}
+
+func TestPermanent(t *testing.T) {
+	r, u := newTestRelay(t)
+	tests := []struct {
+		code      int
+		permanent bool
+	}{
+		{6, true},
+		{7, true},
+		{13, true},
+		{9, false},
+		{10, false},
+		{11, false},
+		{16, false},
+		{26, false},
+		{29, false},
+	}
+	for _, test := range tests {
+		track := fmt.Sprintf("Error %d", test.code)
+		u.errors[track] = test.code
+		err := r.submit(&submission{User: "bob", Kind: kindLove, Artist: "Artist", Track: track})
+		var apiErr *lastfm.APIError
+		if !errors.As(err, &apiErr) || apiErr.Code != test.code {
+			t.Errorf("%v: got %v", test.code, err)
+			continue
+		}
+		if got := permanent(err); got != test.permanent {
+			t.Errorf("%v: got permanent %v, want %v", test.code, got, test.permanent)
+		}
+	}
+
+	if err := r.submit(&submission{User: "carol", Kind: kindLove}); !permanent(err) {
+		t.Errorf("got %v for an unknown user, want a permanent error", err)
+	}
+	if err := r.submit(&submission{User: "bob", Kind: "unknown"}); !permanent(err) {
+		t.Errorf("got %v for an unknown kind, want a permanent error", err)
+	}
+	if permanent(errors.New("connection refused")) {
+		t.Error("network errors must be retried")
+	}
+}
+
+func TestForwardPending(t *testing.T) {
+	r, u := newTestRelay(t)
+	// The session key of alice was revoked.
+	u.errors["sk-alice"] = 9
+	u.errors["Rejected"] = 6
+
+	for _, s := range []*submission{
+		{User: "alice", Kind: kindLove, Artist: "Artist", Track: "Alice 1"},
+		{User: "bob", Kind: kindLove, Artist: "Artist", Track: "Bob 1"},
+		{User: "alice", Kind: kindScrobble, Scrobbles: []lastfm.Scrobble{{Artist: "Artist", Track: "Alice 2", Timestamp: 1600000000}}},
+		{User: "bob", Kind: kindLove, Artist: "Artist", Track: "Rejected"},
+		{User: "bob", Kind: kindScrobble, Scrobbles: []lastfm.Scrobble{{Artist: "Artist", Track: "Bob 2", Timestamp: 1600000000}}},
+	} {
+		if err := r.queue.push(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.forwardPending()
+
+	// The submissions of alice are held back in order after the first one
+	// failed, while those of bob are forwarded or dropped.
+	if got := strings.Join(u.tracks(), ","); got != "Alice 1,Bob 1,Rejected,Bob 2" {
+		t.Errorf("got forwarded %v", got)
+	}
+	pending, err := r.queue.pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].Track != "Alice 1" || pending[1].Scrobbles[0].Track != "Alice 2" {
+		t.Errorf("got pending %+v, want the submissions of alice", pending)
+	}
+	failed, err := ioutil.ReadDir(r.queue.failed)
+	if err != nil || len(failed) != 1 {
+		t.Errorf("got %d failed submissions, %v, want the rejected one", len(failed), err)
+	}
+}
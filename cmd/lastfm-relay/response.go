@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+type lfm struct {
+	XMLName xml.Name    `xml:"lfm"`
+	Status  string      `xml:"status,attr"`
+	Inner   interface{} `xml:",omitempty"`
+}
+
+type lfmError struct {
+	XMLName xml.Name `xml:"error"`
+	Code    int      `xml:"code,attr"`
+	Message string   `xml:",chardata"`
+}
+
+type sessionResponse struct {
+	XMLName    xml.Name `xml:"session" json:"-"`
+	Name       string   `xml:"name" json:"name"`
+	Key        string   `xml:"key" json:"key"`
+	Subscriber int      `xml:"subscriber" json:"subscriber"`
+}
+
+type correctedValue struct {
+	Corrected string `xml:"corrected,attr" json:"corrected"`
+	Text      string `xml:",chardata" json:"#text"`
+}
+
+type ignoredMessage struct {
+	Code string `xml:"code,attr" json:"code"`
+	Text string `xml:",chardata" json:"#text"`
+}
+
+type scrobbleResponse struct {
+	Track          correctedValue `xml:"track" json:"track"`
+	Artist         correctedValue `xml:"artist" json:"artist"`
+	Album          correctedValue `xml:"album" json:"album"`
+	AlbumArtist    correctedValue `xml:"albumArtist" json:"albumArtist"`
+	Timestamp      string         `xml:"timestamp" json:"timestamp"`
+	IgnoredMessage ignoredMessage `xml:"ignoredMessage" json:"ignoredMessage"`
+}
+
+type scrobblesResponse struct {
+	XMLName  xml.Name           `xml:"scrobbles" json:"-"`
+	Accepted int                `xml:"accepted,attr" json:"-"`
+	Ignored  int                `xml:"ignored,attr" json:"-"`
+	Scrobble []scrobbleResponse `xml:"scrobble" json:"scrobble"`
+	Attr     struct {
+		Accepted int `json:"accepted"`
+		Ignored  int `json:"ignored"`
+	} `xml:"-" json:"@attr"`
+}
+
+type nowPlayingResponse struct {
+	XMLName        xml.Name       `xml:"nowplaying" json:"-"`
+	Track          correctedValue `xml:"track" json:"track"`
+	Artist         correctedValue `xml:"artist" json:"artist"`
+	Album          correctedValue `xml:"album" json:"album"`
+	AlbumArtist    correctedValue `xml:"albumArtist" json:"albumArtist"`
+	IgnoredMessage ignoredMessage `xml:"ignoredMessage" json:"ignoredMessage"`
+}
+
+// newScrobblesResponse reports every queued scrobble as accepted, since the
+// relay accepts scrobbles before forwarding them.
+func newScrobblesResponse(scrobbles []lastfm.Scrobble) *scrobblesResponse {
+	resp := &scrobblesResponse{Accepted: len(scrobbles)}
+	resp.Attr.Accepted = len(scrobbles)
+	for _, s := range scrobbles {
+		resp.Scrobble = append(resp.Scrobble, scrobbleResponse{
+			Track:          correctedValue{Corrected: "0", Text: s.Track},
+			Artist:         correctedValue{Corrected: "0", Text: s.Artist},
+			Album:          correctedValue{Corrected: "0", Text: s.Album},
+			AlbumArtist:    correctedValue{Corrected: "0", Text: s.AlbumArtist},
+			Timestamp:      strconv.FormatInt(s.Timestamp, 10),
+			IgnoredMessage: ignoredMessage{Code: "0"},
+		})
+	}
+	return resp
+}
+
+func newNowPlayingResponse(s lastfm.Scrobble) *nowPlayingResponse {
+	return &nowPlayingResponse{
+		Track:          correctedValue{Corrected: "0", Text: s.Track},
+		Artist:         correctedValue{Corrected: "0", Text: s.Artist},
+		Album:          correctedValue{Corrected: "0", Text: s.Album},
+		AlbumArtist:    correctedValue{Corrected: "0", Text: s.AlbumArtist},
+		IgnoredMessage: ignoredMessage{Code: "0"},
+	}
+}
+
+// writeResponse writes v wrapped in the LastFM response envelope, as JSON
+// when requested using `format=json`, or XML otherwise.
+func writeResponse(w http.ResponseWriter, req *http.Request, v interface{}) {
+	if req.Form.Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		body := map[string]interface{}{}
+		switch resp := v.(type) {
+		case sessionResponse:
+			body["session"] = resp
+		case *scrobblesResponse:
+			body["scrobbles"] = resp
+		case *nowPlayingResponse:
+			body["nowplaying"] = resp
+		}
+		json.NewEncoder(w).Encode(body)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(lfm{Status: "ok", Inner: v})
+}
+
+func writeError(w http.ResponseWriter, req *http.Request, status, code int, message string) {
+	if req.Form.Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": code, "message": message})
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(lfm{Status: "failed", Inner: lfmError{Code: code, Message: message}})
+}
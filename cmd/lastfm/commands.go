@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/album"
+	"git.maych.in/thunderbottom/lastfm-go/api/artist"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+	"git.maych.in/thunderbottom/lastfm-go/export"
+	"git.maych.in/thunderbottom/lastfm-go/rewrite"
+)
+
+// env is the state shared by the commands.
+type env struct {
+	cfg    *config
+	client *lastfm.Client
+	out    *printer
+	user   string
+	flags  *flag.FlagSet
+	args   []string
+}
+
+// setup parses the flags of a command, registered by define, then loads the
+// config and creates the client.
+func setup(name string, args []string, define func(*flag.FlagSet)) (e *env, err error) {
+	e = &env{out: &printer{w: os.Stdout}}
+	e.flags = flag.NewFlagSet(name, flag.ContinueOnError)
+	e.flags.BoolVar(&e.out.json, "json", false, "print JSON instead of a table")
+	e.flags.StringVar(&e.user, "user", "", "LastFM user, defaults to the logged in user")
+	if define != nil {
+		define(e.flags)
+	}
+	if err = e.flags.Parse(args); err != nil {
+		return nil, usageError(err.Error())
+	}
+	e.args = e.flags.Args()
+
+	if e.cfg, err = loadConfig(); err != nil {
+		return nil, err
+	}
+	if e.client, err = e.cfg.client(); err != nil {
+		return nil, err
+	}
+	if e.user == "" {
+		e.user = e.cfg.Username
+	}
+	return e, nil
+}
+
+func (e *env) requireUser() error {
+	if e.user == "" {
+		return usageError("no user given, use -user or run `lastfm auth login`")
+	}
+	return nil
+}
+
+func (e *env) requireSession() error {
+	if e.client.SessionKey() == "" {
+		return usageError("not logged in, run `lastfm auth login` first")
+	}
+	return nil
+}
+
+func cmdAuth(args []string) error {
+	if len(args) == 0 || args[0] != "login" {
+		return usageError("usage: lastfm auth login")
+	}
+	e, err := setup("auth login", args[1:], nil)
+	if err != nil {
+		return err
+	}
+	token, err := e.client.GetToken()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Authorize this application by visiting:\n\n  %v\n\nthen press Enter to continue.\n", e.client.AuthURL(token))
+	if _, err = bufio.NewReader(os.Stdin).ReadString('\n'); err != nil && err != io.EOF {
+		return err
+	}
+
+	auth, err := e.client.GetSession(token)
+	if err != nil {
+		return err
+	}
+	e.cfg.SessionKey, e.cfg.Username = auth.Key, auth.Name
+	if err = e.cfg.save(); err != nil {
+		return err
+	}
+	return e.out.print(map[string]string{"username": auth.Name}, []string{"LOGGED IN AS"}, [][]string{{auth.Name}})
+}
+
+func cmdNowPlaying(args []string) error {
+	e, err := setup("np", args, nil)
+	if err != nil {
+		return err
+	}
+	if err = e.requireUser(); err != nil {
+		return err
+	}
+	rt, err := user.New(e.client, e.user).GetRecentTracks(false, 1)
+	if err != nil {
+		return err
+	}
+	var playing []user.RecentTrack
+	for _, t := range rt.List() {
+		if t.NowPlaying {
+			playing = append(playing, t)
+		}
+	}
+	return printTracks(e, playing)
+}
+
+func cmdScrobble(args []string) error {
+	var s lastfm.Scrobble
+	var timestamp, rules string
+	var dryRun bool
+	e, err := setup("scrobble", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&s.Artist, "artist", "", "artist name")
+		fs.StringVar(&s.Track, "track", "", "track name")
+		fs.StringVar(&s.Album, "album", "", "album name")
+		fs.StringVar(&s.AlbumArtist, "album-artist", "", "album artist name")
+		fs.Int64Var(&s.Duration, "duration", 0, "track duration in seconds")
+		fs.StringVar(&timestamp, "time", "", "time the track started playing, defaults to now")
+		fs.StringVar(&rules, "rules", "", "JSON file of rewrite rules applied before submitting")
+		fs.BoolVar(&dryRun, "dry-run", false, "show the rewrite rules matching each scrobble without submitting")
+	})
+	if err != nil {
+		return err
+	}
+	rs := &rewrite.RuleSet{}
+	if rules != "" {
+		if rs, err = rewrite.Load(rules); err != nil {
+			return err
+		}
+	}
+	if !dryRun {
+		if err = e.requireSession(); err != nil {
+			return err
+		}
+	}
+
+	var scrobbles []lastfm.Scrobble
+	if s.Artist != "" || s.Track != "" {
+		s.Timestamp = time.Now().Unix()
+		if timestamp != "" {
+			played, err := parseTime(timestamp)
+			if err != nil {
+				return err
+			}
+			s.Timestamp = played.Unix()
+		}
+		s.ChosenByUser = true
+		scrobbles = append(scrobbles, s)
+	} else if scrobbles, err = readScrobbles(os.Stdin); err != nil {
+		return err
+	}
+
+	if dryRun {
+		var results []rewrite.Result
+		var rows [][]string
+		for _, scrobble := range scrobbles {
+			result := rs.Apply(scrobble)
+			results = append(results, result)
+			rows = append(rows, []string{result.Scrobble.Artist, result.Scrobble.Track, string(result.Action), strings.Join(result.Fired, ", ")})
+		}
+		return e.out.print(results, []string{"ARTIST", "TRACK", "ACTION", "RULES"}, rows)
+	}
+
+	results, err := rs.Scrobbler(track.New(e.client, e.user, false).Scrobbler()).Scrobble(scrobbles)
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for i, result := range results {
+		status := "accepted"
+		if !result.Accepted {
+			status = "ignored: " + result.IgnoredMessage
+		}
+		rows = append(rows, []string{scrobbles[i].Artist, scrobbles[i].Track, status})
+	}
+	return e.out.print(results, []string{"ARTIST", "TRACK", "STATUS"}, rows)
+}
+
+// readScrobbles reads one scrobble per line from r, as tab-separated artist,
+// track, and optionally album and time fields.
+func readScrobbles(r io.Reader) (scrobbles []lastfm.Scrobble, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) < 2 {
+			return nil, usageError(fmt.Sprintf("stdin line %v: expected artist<TAB>track[<TAB>album[<TAB>time]]", line))
+		}
+		s := lastfm.Scrobble{Artist: fields[0], Track: fields[1], Timestamp: time.Now().Unix(), ChosenByUser: true}
+		if len(fields) > 2 {
+			s.Album = fields[2]
+		}
+		if len(fields) > 3 {
+			played, err := parseTime(fields[3])
+			if err != nil {
+				return nil, usageError(fmt.Sprintf("stdin line %v: %v", line, err))
+			}
+			s.Timestamp = played.Unix()
+		}
+		scrobbles = append(scrobbles, s)
+	}
+	return scrobbles, scanner.Err()
+}
+
+func cmdLove(love bool) func([]string) error {
+	name := "love"
+	if !love {
+		name = "unlove"
+	}
+	return func(args []string) error {
+		e, err := setup(name, args, nil)
+		if err != nil {
+			return err
+		}
+		if len(e.args) != 2 {
+			return usageError(fmt.Sprintf("usage: lastfm %v ARTIST TRACK", name))
+		}
+		if err = e.requireSession(); err != nil {
+			return err
+		}
+		t := track.New(e.client, e.user, false)
+		if love {
+			err = t.Love(e.args[0], e.args[1])
+		} else {
+			err = t.Unlove(e.args[0], e.args[1])
+		}
+		if err != nil {
+			return err
+		}
+		result := map[string]string{"artist": e.args[0], "track": e.args[1], "status": name + "d"}
+		return e.out.print(result, []string{"ARTIST", "TRACK", "STATUS"}, [][]string{{e.args[0], e.args[1], name + "d"}})
+	}
+}
+
+func cmdTop(args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: lastfm top artists|albums|tracks [-period PERIOD]")
+	}
+	kind := args[0]
+	var period string
+	var page, limit int
+	e, err := setup("top "+kind, args[1:], func(fs *flag.FlagSet) {
+		fs.StringVar(&period, "period", string(user.PeriodOverall), "overall, 7day, 1month, 3month, 6month or 12month")
+		fs.IntVar(&page, "page", 1, "page to show")
+		fs.IntVar(&limit, "limit", 20, "entries per page")
+	})
+	if err != nil {
+		return err
+	}
+	if err = e.requireUser(); err != nil {
+		return err
+	}
+	if err = user.Period(period).Validate(); err != nil {
+		return usageError(err.Error())
+	}
+	e.client.SetLimit(limit)
+
+	u := user.New(e.client, e.user)
+	var entries []user.ChartEntry
+	switch kind {
+	case "artists":
+		ta, err := u.GetTopArtists(period, page)
+		if err != nil {
+			return err
+		}
+		entries = ta.List()
+	case "albums":
+		ta, err := u.GetTopAlbums(period, page)
+		if err != nil {
+			return err
+		}
+		entries = ta.List()
+	case "tracks":
+		tt, err := u.GetTopTracks(period, page)
+		if err != nil {
+			return err
+		}
+		entries = tt.List()
+	default:
+		return usageError("usage: lastfm top artists|albums|tracks [-period PERIOD]")
+	}
+
+	var rows [][]string
+	for _, entry := range entries {
+		rows = append(rows, []string{strconv.Itoa(entry.Rank), entry.Artist, entry.Name, strconv.FormatInt(entry.Playcount, 10)})
+	}
+	return e.out.print(entries, []string{"RANK", "ARTIST", "NAME", "PLAYS"}, rows)
+}
+
+func cmdRecent(args []string) error {
+	var from, to string
+	var limit int
+	e, err := setup("recent", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&from, "from", "", "show tracks played after this time")
+		fs.StringVar(&to, "to", "", "show tracks played before this time")
+		fs.IntVar(&limit, "limit", 50, "number of tracks")
+	})
+	if err != nil {
+		return err
+	}
+	if err = e.requireUser(); err != nil {
+		return err
+	}
+	fromTs, toTs, err := parseRange(from, to)
+	if err != nil {
+		return err
+	}
+	e.client.SetLimit(limit)
+
+	rt, err := user.New(e.client, e.user).GetRecentTracksRange(false, fromTs, toTs, 1)
+	if err != nil {
+		return err
+	}
+	return printTracks(e, rt.List())
+}
+
+func cmdTags(args []string) error {
+	if len(args) == 0 || (args[0] != "add" && args[0] != "remove") {
+		return usageError("usage: lastfm tags add|remove -artist ARTIST [-album ALBUM | -track TRACK] TAG...")
+	}
+	action := args[0]
+	var artistName, albumName, trackName string
+	e, err := setup("tags "+action, args[1:], func(fs *flag.FlagSet) {
+		fs.StringVar(&artistName, "artist", "", "artist name")
+		fs.StringVar(&albumName, "album", "", "album name")
+		fs.StringVar(&trackName, "track", "", "track name")
+	})
+	if err != nil {
+		return err
+	}
+	if artistName == "" || len(e.args) == 0 || (albumName != "" && trackName != "") {
+		return usageError("usage: lastfm tags add|remove -artist ARTIST [-album ALBUM | -track TRACK] TAG...")
+	}
+	if err = e.requireSession(); err != nil {
+		return err
+	}
+
+	tags := e.args
+	switch {
+	case action == "add" && albumName != "":
+		err = album.New(e.client, e.user, false).AddTags(artistName, albumName, tags)
+	case action == "add" && trackName != "":
+		err = track.New(e.client, e.user, false).AddTags(artistName, trackName, tags)
+	case action == "add":
+		err = artist.New(e.client, e.user, false).AddTags(artistName, tags)
+	default:
+		for _, tag := range tags {
+			switch {
+			case albumName != "":
+				err = album.New(e.client, e.user, false).RemoveTag(artistName, albumName, tag)
+			case trackName != "":
+				err = track.New(e.client, e.user, false).RemoveTag(artistName, trackName, tag)
+			default:
+				err = artist.New(e.client, e.user, false).RemoveTag(artistName, tag)
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, tag := range tags {
+		rows = append(rows, []string{tag, action})
+	}
+	return e.out.print(map[string]interface{}{"tags": tags, "action": action}, []string{"TAG", "ACTION"}, rows)
+}
+
+func cmdExport(args []string) (err error) {
+	var kind, format, output, period, from, to, columns, tz string
+	e, err := setup("export", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&kind, "kind", "recent", "recent, loved, top-artists, top-albums or top-tracks")
+		fs.StringVar(&format, "format", "csv", "csv, jsonl or scrobbler-log")
+		fs.StringVar(&output, "o", "", "output file, defaults to stdout")
+		fs.StringVar(&period, "period", string(user.PeriodOverall), "period of top charts")
+		fs.StringVar(&from, "from", "", "export tracks played after this time")
+		fs.StringVar(&to, "to", "", "export tracks played before this time")
+		fs.StringVar(&columns, "columns", "", "comma-separated CSV columns")
+		fs.StringVar(&tz, "tz", "UTC", "time zone of the time_local CSV column")
+	})
+	if err != nil {
+		return err
+	}
+	if err = e.requireUser(); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		var f *os.File
+		if f, err = os.Create(output); err != nil {
+			return err
+		}
+		// the records are only known to be written once the file is closed
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+
+	exporter := export.New(w, export.FormatCSV)
+	switch format {
+	case "csv":
+	case "jsonl":
+		exporter.Format = export.FormatJSONLines
+	case "scrobbler-log":
+		exporter.Format = export.FormatScrobblerLog
+	default:
+		return usageError(fmt.Sprintf("unknown format %q", format))
+	}
+	if columns != "" {
+		for _, column := range strings.Split(columns, ",") {
+			exporter.Columns = append(exporter.Columns, export.Column(strings.TrimSpace(column)))
+		}
+	}
+	if exporter.Location, err = time.LoadLocation(tz); err != nil {
+		return usageError(err.Error())
+	}
+	e.client.SetLimit(200)
+
+	u := user.New(e.client, e.user)
+	var n int
+	switch kind {
+	case "recent":
+		var fromTs, toTs int64
+		if fromTs, toTs, err = parseRange(from, to); err != nil {
+			return err
+		}
+		n, err = exporter.RecentTracks(u, fromTs, toTs)
+	case "loved":
+		n, err = exporter.LovedTracks(u)
+	case "top-artists":
+		n, err = exporter.TopArtists(u, user.Period(period))
+	case "top-albums":
+		n, err = exporter.TopAlbums(u, user.Period(period))
+	case "top-tracks":
+		n, err = exporter.TopTracks(u, user.Period(period))
+	default:
+		return usageError(fmt.Sprintf("unknown kind %q", kind))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %v records\n", n)
+	return nil
+}
+
+func printTracks(e *env, tracks []user.RecentTrack) error {
+	var rows [][]string
+	for _, t := range tracks {
+		played := "now playing"
+		if !t.NowPlaying {
+			played = t.Time.Local().Format("2006-01-02 15:04")
+		}
+		rows = append(rows, []string{played, t.Artist, t.Track, t.Album})
+	}
+	if tracks == nil {
+		tracks = []user.RecentTrack{}
+	}
+	return e.out.print(tracks, []string{"TIME", "ARTIST", "TRACK", "ALBUM"}, rows)
+}
+
+// parseTime parses a unix timestamp, a date or an RFC 3339 time.
+func parseTime(s string) (time.Time, error) {
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, usageError(fmt.Sprintf("invalid time %q, expected unixtime, YYYY-MM-DD or RFC 3339", s))
+}
+
+func parseRange(from, to string) (fromTs, toTs int64, err error) {
+	if from != "" {
+		t, err := parseTime(from)
+		if err != nil {
+			return 0, 0, err
+		}
+		fromTs = t.Unix()
+	}
+	if to != "" {
+		t, err := parseTime(to)
+		if err != nil {
+			return 0, 0, err
+		}
+		toTs = t.Unix()
+	}
+	return
+}
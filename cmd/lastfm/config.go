@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// config is stored as JSON in the XDG config directory, usually
+// `~/.config/lastfm/config.json`.
+type config struct {
+	APIKey     string `json:"api_key"`
+	APISecret  string `json:"api_secret"`
+	SessionKey string `json:"session_key,omitempty"`
+	Username   string `json:"username,omitempty"`
+	// Service is either `lastfm` or `librefm`. BaseURL and AuthURL
+	// override the service URLs for self-hosted servers.
+	Service string `json:"service,omitempty"`
+	BaseURL string `json:"base_url,omitempty"`
+	AuthURL string `json:"auth_url,omitempty"`
+}
+
+func configPath() (string, error) {
+	if path := os.Getenv("LASTFM_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lastfm", "config.json"), nil
+}
+
+// loadConfig reads the config file, and applies the LASTFM_API_KEY,
+// LASTFM_API_SECRET and LASTFM_SESSION_KEY environment variables.
+func loadConfig() (cfg *config, err error) {
+	cfg = &config{}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if err = json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+	}
+
+	if v := os.Getenv("LASTFM_API_KEY"); v != "" {
+		cfg.APIKey = v
+	}
+	if v := os.Getenv("LASTFM_API_SECRET"); v != "" {
+		cfg.APISecret = v
+	}
+	if v := os.Getenv("LASTFM_SESSION_KEY"); v != "" {
+		cfg.SessionKey = v
+	}
+	return cfg, nil
+}
+
+func (cfg *config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// client returns a lastfm.Client for the configured service and session.
+func (cfg *config) client() (*lastfm.Client, error) {
+	if cfg.APIKey == "" || cfg.APISecret == "" {
+		return nil, usageError("api_key and api_secret must be set in the config file or the environment")
+	}
+	service := lastfm.LastFM
+	if strings.EqualFold(cfg.Service, "librefm") {
+		service = lastfm.LibreFM
+	}
+	if cfg.BaseURL != "" {
+		service = lastfm.Service{Name: cfg.BaseURL, BaseURL: cfg.BaseURL, AuthURL: cfg.AuthURL}
+	}
+	client := lastfm.NewWithService(service, cfg.APIKey, cfg.APISecret)
+	client.SetUserAgent("lastfm-go/cmd")
+	client.SetSessionKey(cfg.SessionKey)
+	return &client, nil
+}
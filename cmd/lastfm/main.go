@@ -0,0 +1,108 @@
+// Command lastfm is a command-line client for LastFM, covering
+// authentication, scrobbling, charts and exports.
+//
+// Usage:
+//
+//	lastfm <command> [flags] [arguments]
+//
+// Commands:
+//
+//	auth login                         authorize the tool and store the session
+//	np                                 show the track playing now
+//	scrobble                           scrobble tracks from flags, or stdin
+//	love, unlove ARTIST TRACK          love or unlove a track
+//	top artists|albums|tracks          show the top charts of a user
+//	recent                             show the recent tracks of a user
+//	tags add|remove TAG...             tag an artist, album or track
+//	export                             export scrobbles, loved tracks or charts
+//
+// The API key, secret and session are read from `lastfm/config.json` in the
+// XDG config directory. Every command accepts -json to print JSON instead of
+// a table.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// Exit codes, mapped from the LastFM error classes.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitAuth        = 3
+	exitAPIKey      = 4
+	exitNotFound    = 5
+	exitRateLimit   = 6
+	exitUnavailable = 7
+	exitNetwork     = 8
+)
+
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+var commands = map[string]func(args []string) error{
+	"auth":     cmdAuth,
+	"export":   cmdExport,
+	"love":     cmdLove(true),
+	"np":       cmdNowPlaying,
+	"recent":   cmdRecent,
+	"scrobble": cmdScrobble,
+	"tags":     cmdTags,
+	"top":      cmdTop,
+	"unlove":   cmdLove(false),
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: lastfm <auth|np|scrobble|love|unlove|top|recent|tags|export> [flags]")
+		os.Exit(exitUsage)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "lastfm: unknown command %q\n", os.Args[1])
+		os.Exit(exitUsage)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "lastfm:", err)
+		os.Exit(exitCode(err))
+	}
+	os.Exit(exitOK)
+}
+
+// exitCode maps err to the exit code of its LastFM error class.
+func exitCode(err error) int {
+	var usage usageError
+	if errors.As(err, &usage) {
+		return exitUsage
+	}
+	var apiErr *lastfm.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case 4, 9, 14, 15, 17:
+			return exitAuth
+		case 10, 26:
+			return exitAPIKey
+		case 6, 7:
+			return exitNotFound
+		case 29:
+			return exitRateLimit
+		case 8, 11, 16:
+			return exitUnavailable
+		}
+		return exitError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return exitNetwork
+	}
+	return exitError
+}
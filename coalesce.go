@@ -0,0 +1,78 @@
+package lastfm
+
+import (
+	"context"
+	"net/url"
+	"sync"
+)
+
+// response is the raw response of a LastFM API request.
+type response struct {
+	status      int
+	contentType string
+	body        []byte
+}
+
+// flightGroup coalesces identical requests which are in flight at the same time.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	resp *response
+	err  error
+	// callers is the number of callers which joined the call.
+	callers int
+}
+
+// SetCoalesce enables or disables coalescing of identical GET requests made
+// concurrently using the Client. Requests are identical when their method and
+// non-empty parameters match.
+func (client *Client) SetCoalesce(coalesce bool) {
+	if !coalesce {
+		client.flight = nil
+		return
+	}
+	client.flight = &flightGroup{calls: make(map[string]*flightCall)}
+}
+
+// do runs fn once for all callers sharing key, and waits for its result or
+// for ctx to be done. fn keeps running for other callers when ctx is done.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (*response, error)) (*response, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go func() {
+			call.resp, call.err = fn()
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.callers++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.resp, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flightKey returns the key identifying identical requests, made of the
+// method and the non-empty parameters of provider in sorted order.
+func flightKey(provider *Provider) string {
+	params := url.Values{}
+	for key, value := range provider.Params {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	return provider.Method + "?" + params.Encode()
+}
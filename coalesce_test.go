@@ -0,0 +1,144 @@
+package lastfm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type artistInfo struct {
+	Artist struct {
+		Name string `json:"name"`
+	} `json:"artist"`
+}
+
+// newBlockedServer returns a Client for a stand-in API server which counts
+// its requests, and holds the responses until release is closed.
+func newBlockedServer(t *testing.T) (client *Client, requests *int64, release chan struct{}) {
+	requests = new(int64)
+	release = make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(requests, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"artist":{"name":%q}}`, r.URL.Query().Get("artist"))
+	}))
+	t.Cleanup(srv.Close)
+	c := NewWithService(Service{Name: "stub", BaseURL: srv.URL}, "key", "secret")
+	c.SetCoalesce(true)
+	return &c, requests, release
+}
+
+// waitCallers waits until n callers joined the call in flight for key.
+func waitCallers(client *Client, key string, n int) {
+	for {
+		client.flight.mu.Lock()
+		call, ok := client.flight.calls[key]
+		joined := ok && call.callers >= n
+		client.flight.mu.Unlock()
+		if joined {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	client, requests, release := newBlockedServer(t)
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make([]*artistInfo, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = &artistInfo{}
+			// Empty parameters do not change the request.
+			errs[i] = client.Request(&Provider{
+				Method:   "artist.getInfo",
+				Params:   map[string]string{"artist": "Cher", "lang": ""},
+				Response: results[i],
+				Type:     "GET",
+			})
+		}(i)
+	}
+	waitCallers(client, "artist.getInfo?artist=Cher", n)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt64(requests); got != 1 {
+		t.Errorf("got %d upstream requests for %d identical requests, want 1", got, n)
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil || results[i].Artist.Name != "Cher" {
+			t.Fatalf("request %d: got %+v, %v", i, results[i], errs[i])
+		}
+	}
+	// Each caller decoded its own copy of the response.
+	results[0].Artist.Name = "changed"
+	if results[1].Artist.Name != "Cher" {
+		t.Error("callers share the decoded response")
+	}
+}
+
+func TestCoalesceCancel(t *testing.T) {
+	client, requests, release := newBlockedServer(t)
+	get := func(ctx context.Context, v *artistInfo) error {
+		return client.RequestContext(ctx, &Provider{
+			Method:   "artist.getInfo",
+			Params:   map[string]string{"artist": "Cher"},
+			Response: v,
+			Type:     "GET",
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() { cancelled <- get(ctx, &artistInfo{}) }()
+	waitCallers(client, "artist.getInfo?artist=Cher", 1)
+
+	other := &artistInfo{}
+	done := make(chan error, 1)
+	go func() { done <- get(context.Background(), other) }()
+	waitCallers(client, "artist.getInfo?artist=Cher", 2)
+
+	cancel()
+	if err := <-cancelled; err != context.Canceled {
+		t.Errorf("got %v for the cancelled caller, want context.Canceled", err)
+	}
+	close(release)
+	if err := <-done; err != nil || other.Artist.Name != "Cher" {
+		t.Errorf("got %+v, %v for the other caller", other, err)
+	}
+	if got := atomic.LoadInt64(requests); got != 1 {
+		t.Errorf("got %d upstream requests, want 1", got)
+	}
+}
+
+func TestCoalesceDistinct(t *testing.T) {
+	client, requests, release := newBlockedServer(t)
+	close(release)
+
+	var wg sync.WaitGroup
+	for _, artist := range []string{"Cher", "Madonna"} {
+		wg.Add(1)
+		go func(artist string) {
+			defer wg.Done()
+			v := &artistInfo{}
+			if err := client.Request(&Provider{Method: "artist.getInfo", Params: map[string]string{"artist": artist}, Response: v, Type: "GET"}); err != nil || v.Artist.Name != artist {
+				t.Errorf("%v: got %+v, %v", artist, v, err)
+			}
+		}(artist)
+	}
+	wg.Wait()
+	if got := atomic.LoadInt64(requests); got != 2 {
+		t.Errorf("got %d upstream requests for different parameters, want 2", got)
+	}
+}
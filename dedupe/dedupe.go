@@ -0,0 +1,116 @@
+// Package dedupe removes scrobbles which were already submitted to LastFM,
+// by comparing them with the recent tracks of the user.
+package dedupe
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+// DefaultTolerance is the Tolerance of a new Deduper.
+const DefaultTolerance = 30 * time.Second
+
+// Check fetches the recent tracks of the user covering the timestamps of the
+// candidates, and filters out the candidates already present.
+func (d *Deduper) Check(candidates []lastfm.Scrobble) (result *Result, err error) {
+	if len(candidates) == 0 {
+		return &Result{}, nil
+	}
+	from, to := candidates[0].Timestamp, candidates[0].Timestamp
+	for _, candidate := range candidates {
+		if candidate.Timestamp < from {
+			from = candidate.Timestamp
+		}
+		if candidate.Timestamp > to {
+			to = candidate.Timestamp
+		}
+	}
+	tolerance := int64(d.Tolerance / time.Second)
+	from, to = from-tolerance, to+tolerance
+
+	var history []user.RecentTrack
+	for page := 1; ; page++ {
+		rt, err := d.user.GetRecentTracksRange(false, from, to, page)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, rt.List()...)
+		if page >= rt.TotalPages() {
+			break
+		}
+	}
+	return Filter(candidates, history, d.Tolerance), nil
+}
+
+// Filter returns the candidates which do not match a play in history, or an
+// earlier candidate. Artist and track names are compared after normalizing
+// case and whitespace, and timestamps may differ by up to tolerance. Each play
+// in history matches at most one candidate.
+func Filter(candidates []lastfm.Scrobble, history []user.RecentTrack, tolerance time.Duration) (result *Result) {
+	result = &Result{}
+	used := make([]bool, len(history))
+	var accepted []int
+
+	for i, candidate := range candidates {
+		played := time.Unix(candidate.Timestamp, 0)
+		name := normalize(candidate.Artist) + "\x00" + normalize(candidate.Track)
+
+		reason := ""
+		for h, play := range history {
+			if used[h] || play.NowPlaying || normalize(play.Artist)+"\x00"+normalize(play.Track) != name {
+				continue
+			}
+			if diff := absDuration(play.Time.Sub(played)); diff <= tolerance {
+				used[h] = true
+				reason = fmt.Sprintf("already scrobbled at %v (%v apart)", play.Time.UTC().Format(time.RFC3339), diff)
+				break
+			}
+		}
+		if reason == "" {
+			for _, a := range accepted {
+				other := candidates[a]
+				if normalize(other.Artist)+"\x00"+normalize(other.Track) != name {
+					continue
+				}
+				if diff := absDuration(time.Unix(other.Timestamp, 0).Sub(played)); diff <= tolerance {
+					reason = fmt.Sprintf("duplicate of candidate %v (%v apart)", a, diff)
+					break
+				}
+			}
+		}
+
+		if reason != "" {
+			result.Dropped = append(result.Dropped, Dropped{Index: i, Scrobble: candidate, Reason: reason})
+			continue
+		}
+		accepted = append(accepted, i)
+		result.Safe = append(result.Safe, candidate)
+	}
+	return
+}
+
+// normalize lower-cases s and collapses its whitespace.
+func normalize(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// New returns an instance of the Deduper for the provided user, with
+// Tolerance set to DefaultTolerance.
+func New(client *lastfm.Client, username string) (deduper *Deduper) {
+	deduper = &Deduper{
+		user:      user.New(client, username),
+		Tolerance: DefaultTolerance,
+	}
+	return
+}
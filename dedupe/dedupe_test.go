@@ -0,0 +1,143 @@
+package dedupe
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+const base = 1700000000
+
+func candidate(artist, track string, offset int64) lastfm.Scrobble {
+	return lastfm.Scrobble{Artist: artist, Track: track, Timestamp: base + offset}
+}
+
+func play(artist, track string, offset int64) user.RecentTrack {
+	return user.RecentTrack{Artist: artist, Track: track, Time: time.Unix(base+offset, 0)}
+}
+
+func TestFilter(t *testing.T) {
+	nowPlaying := play("Cher", "Believe", 0)
+	nowPlaying.NowPlaying = true
+
+	tests := []struct {
+		name       string
+		candidates []lastfm.Scrobble
+		history    []user.RecentTrack
+		// dropped are the indexes of the dropped candidates, and reasons
+		// the start of their reasons.
+		dropped []int
+		reasons []string
+	}{
+		{
+			name:       "no history",
+			candidates: []lastfm.Scrobble{candidate("Cher", "Believe", 0)},
+		},
+		{
+			name:       "same timestamp",
+			candidates: []lastfm.Scrobble{candidate("Cher", "Believe", 0)},
+			history:    []user.RecentTrack{play("Cher", "Believe", 0)},
+			dropped:    []int{0},
+			reasons:    []string{"already scrobbled at 2023-11-14T22:13:20Z (0s apart)"},
+		},
+		{
+			name:       "within tolerance",
+			candidates: []lastfm.Scrobble{candidate("Cher", "Believe", 0), candidate("Cher", "Strong Enough", 300)},
+			history:    []user.RecentTrack{play("Cher", "Believe", -30), play("Cher", "Strong Enough", 330)},
+			dropped:    []int{0, 1},
+			reasons:    []string{"already scrobbled", "already scrobbled"},
+		},
+		{
+			name:       "beyond tolerance",
+			candidates: []lastfm.Scrobble{candidate("Cher", "Believe", 0)},
+			history:    []user.RecentTrack{play("Cher", "Believe", 31), play("Cher", "Believe", -31)},
+		},
+		{
+			name:       "case and whitespace",
+			candidates: []lastfm.Scrobble{candidate(" CHER ", "believe", 0), candidate("Daft  Punk", "Get Lucky", 300)},
+			history:    []user.RecentTrack{play("Cher", "Believe", 10), play("daft punk", "get  lucky ", 300)},
+			dropped:    []int{0, 1},
+			reasons:    []string{"already scrobbled", "already scrobbled"},
+		},
+		{
+			name:       "other track",
+			candidates: []lastfm.Scrobble{candidate("Cher", "Believe", 0)},
+			history:    []user.RecentTrack{play("Cher", "Strong Enough", 0), play("Madonna", "Believe", 0)},
+		},
+		{
+			// The second play of a track repeated within the tolerance is
+			// kept, as the history has a single play of it.
+			name:       "one play per candidate",
+			candidates: []lastfm.Scrobble{candidate("Cher", "Believe", 0), candidate("Cher", "Believe", 20)},
+			history:    []user.RecentTrack{play("Cher", "Believe", 10)},
+			dropped:    []int{0},
+			reasons:    []string{"already scrobbled"},
+		},
+		{
+			name:       "duplicate candidates",
+			candidates: []lastfm.Scrobble{candidate("Cher", "Believe", 0), candidate("Cher", "Strong Enough", 5), candidate("cher", "Believe", 10)},
+			dropped:    []int{2},
+			reasons:    []string{"duplicate of candidate 0 (10s apart)"},
+		},
+		{
+			name:       "repeated candidates",
+			candidates: []lastfm.Scrobble{candidate("Cher", "Believe", 0), candidate("Cher", "Believe", 240)},
+		},
+		{
+			// The track playing has not been scrobbled yet.
+			name:       "now playing",
+			candidates: []lastfm.Scrobble{candidate("Cher", "Believe", 0)},
+			history:    []user.RecentTrack{nowPlaying},
+		},
+	}
+	for _, test := range tests {
+		result := Filter(test.candidates, test.history, DefaultTolerance)
+
+		isDropped := map[int]bool{}
+		for _, i := range test.dropped {
+			isDropped[i] = true
+		}
+		var safe []lastfm.Scrobble
+		for i, c := range test.candidates {
+			if !isDropped[i] {
+				safe = append(safe, c)
+			}
+		}
+
+		var dropped []int
+		var reasons []string
+		for k, d := range result.Dropped {
+			dropped = append(dropped, d.Index)
+			if d.Scrobble != test.candidates[d.Index] {
+				t.Errorf("%v: got dropped scrobble %+v at index %v", test.name, d.Scrobble, d.Index)
+			}
+			if k < len(test.reasons) && !strings.HasPrefix(d.Reason, test.reasons[k]) {
+				reasons = append(reasons, d.Reason)
+			}
+		}
+		if !reflect.DeepEqual(result.Safe, safe) {
+			t.Errorf("%v: got safe %+v, want %+v", test.name, result.Safe, safe)
+		}
+		if !reflect.DeepEqual(dropped, test.dropped) {
+			t.Errorf("%v: got dropped %v, want %v", test.name, dropped, test.dropped)
+		}
+		if reasons != nil {
+			t.Errorf("%v: got reasons %q, want %q", test.name, reasons, test.reasons)
+		}
+	}
+}
+
+func TestFilterTolerance(t *testing.T) {
+	candidates := []lastfm.Scrobble{candidate("Cher", "Believe", 0)}
+	history := []user.RecentTrack{play("Cher", "Believe", 90)}
+	if result := Filter(candidates, history, DefaultTolerance); len(result.Dropped) != 0 {
+		t.Errorf("got dropped %+v with the default tolerance", result.Dropped)
+	}
+	if result := Filter(candidates, history, 2*time.Minute); len(result.Safe) != 0 {
+		t.Errorf("got safe %+v with a tolerance of 2m", result.Safe)
+	}
+}
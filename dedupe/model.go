@@ -0,0 +1,33 @@
+package dedupe
+
+import (
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+// Deduper represents a structure to filter out scrobbles which were already
+// submitted to LastFM.
+type Deduper struct {
+	user *user.User
+
+	// Tolerance is the largest difference between the timestamps of two
+	// scrobbles of the same track for them to be considered duplicates.
+	Tolerance time.Duration
+}
+
+// Dropped is a candidate scrobble which was found to be a duplicate.
+type Dropped struct {
+	// Index is the position of the scrobble in the candidates.
+	Index    int
+	Scrobble lastfm.Scrobble
+	Reason   string
+}
+
+// Result contains the candidates which are safe to submit, and the
+// duplicates which were dropped.
+type Result struct {
+	Safe    []lastfm.Scrobble
+	Dropped []Dropped
+}
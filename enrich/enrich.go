@@ -0,0 +1,252 @@
+// Package enrich fetches LastFM metadata for large numbers of artists, albums
+// and tracks using a bounded pool of workers.
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/album"
+	"git.maych.in/thunderbottom/lastfm-go/api/artist"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+// Enrich looks up metadata for each key in keys, and delivers the results
+// on the returned channel in completion order. The channel is closed once
+// all keys are processed, or ctx is done.
+func (e *Enricher) Enrich(ctx context.Context, keys []Key) <-chan Result {
+	input := make(chan Key)
+	go func() {
+		defer close(input)
+		for _, key := range keys {
+			select {
+			case input <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return e.EnrichChan(ctx, input)
+}
+
+// EnrichChan looks up metadata for each key received from keys until it is
+// closed, and delivers the results on the returned channel in completion order.
+//
+// Requests are made through the lastfm.Client of the Enricher, and are subject
+// to its rate limits.
+func (e *Enricher) EnrichChan(ctx context.Context, keys <-chan Key) <-chan Result {
+	workers := e.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		mu         sync.Mutex
+		progressMu sync.Mutex
+		seen       = map[string]*lookup{}
+		done       int
+		total      int
+		wg         sync.WaitGroup
+	)
+	results := make(chan Result)
+	jobs := make(chan *lookup)
+
+	deliver := func(l *lookup, index int) {
+		select {
+		case results <- Result{Index: index, Key: l.key, Metadata: l.metadata, Err: l.err}:
+		case <-ctx.Done():
+			return
+		}
+		if e.Progress != nil {
+			progressMu.Lock()
+			mu.Lock()
+			done++
+			d, t := done, total
+			mu.Unlock()
+			e.Progress(d, t)
+			progressMu.Unlock()
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for l := range jobs {
+				var metadata *Metadata
+				err := ctx.Err()
+				if err == nil {
+					metadata, err = e.lookup(l.key)
+				}
+
+				mu.Lock()
+				l.done, l.metadata, l.err = true, metadata, err
+				waiters := l.waiters
+				l.waiters = nil
+				mu.Unlock()
+
+				for _, index := range waiters {
+					deliver(l, index)
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for index := 0; ; index++ {
+			var key Key
+			var ok bool
+			select {
+			case key, ok = <-keys:
+			case <-ctx.Done():
+				return
+			}
+			if !ok {
+				return
+			}
+
+			mu.Lock()
+			total++
+			id := normalizeKey(key)
+			l, exists := seen[id]
+			if !exists {
+				l = &lookup{key: key}
+				seen[id] = l
+			}
+			if !l.done {
+				l.waiters = append(l.waiters, index)
+			}
+			completed := l.done
+			mu.Unlock()
+
+			switch {
+			case completed:
+				deliver(l, index)
+			case !exists:
+				select {
+				case jobs <- l:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func (e *Enricher) lookup(key Key) (metadata *Metadata, err error) {
+	switch key.Kind {
+	case KindArtist:
+		ai, err := e.artist.GetInfo(key.Artist, key.MBID, "")
+		if err != nil {
+			return nil, err
+		}
+		a := ai.Artist
+		metadata = &Metadata{
+			Name:      a.Name,
+			Artist:    a.Name,
+			MBID:      a.Mbid,
+			URL:       a.URL,
+			Listeners: lastfm.ParseInt(a.Stats.Listeners),
+			Playcount: lastfm.ParseInt(a.Stats.Playcount),
+			Images:    map[string]string{},
+			Summary:   a.Bio.Summary,
+		}
+		for _, tag := range a.Tags.Tag {
+			metadata.Tags = append(metadata.Tags, tag.Name)
+		}
+		for _, image := range a.Image {
+			metadata.Images[image.Size] = image.Text
+		}
+	case KindAlbum:
+		ai, err := e.album.GetInfo(key.Artist, key.Album, key.MBID, "")
+		if err != nil {
+			return nil, err
+		}
+		a := ai.Album
+		metadata = &Metadata{
+			Name:      a.Name,
+			Artist:    a.Artist,
+			Album:     a.Name,
+			MBID:      a.Mbid,
+			URL:       a.URL,
+			Listeners: lastfm.ParseInt(a.Listeners),
+			Playcount: lastfm.ParseInt(a.Playcount),
+			Images:    map[string]string{},
+			Summary:   a.Wiki.Summary,
+		}
+		for _, tr := range a.Tracks.Track {
+			metadata.Duration += time.Duration(lastfm.ParseInt(tr.Duration)) * time.Second
+		}
+		for _, tag := range a.Tags.Tag {
+			metadata.Tags = append(metadata.Tags, tag.Name)
+		}
+		for _, image := range a.Image {
+			metadata.Images[image.Size] = image.Text
+		}
+	case KindTrack:
+		ti, err := e.track.GetInfo(key.Artist, key.Track, key.MBID)
+		if err != nil {
+			return nil, err
+		}
+		t := ti.Track
+		metadata = &Metadata{
+			Name:      t.Name,
+			Artist:    t.Artist.Name,
+			Album:     t.Album.Title,
+			MBID:      t.Mbid,
+			URL:       t.URL,
+			Listeners: lastfm.ParseInt(t.Listeners),
+			Playcount: lastfm.ParseInt(t.Playcount),
+			Duration:  time.Duration(lastfm.ParseInt(t.Duration)) * time.Millisecond,
+			Images:    map[string]string{},
+			Summary:   t.Wiki.Summary,
+		}
+		for _, tag := range t.Toptags.Tag {
+			metadata.Tags = append(metadata.Tags, tag.Name)
+		}
+		for _, image := range t.Album.Image {
+			metadata.Images[image.Size] = image.Text
+		}
+	default:
+		return nil, fmt.Errorf("enrich: unknown kind %v", key.Kind)
+	}
+	return
+}
+
+// normalizeKey returns the identifier used to detect repeated keys.
+func normalizeKey(key Key) string {
+	if key.MBID != "" {
+		return strconv.Itoa(int(key.Kind)) + "|mbid|" + strings.ToLower(strings.TrimSpace(key.MBID))
+	}
+	fields := []string{strconv.Itoa(int(key.Kind)), key.Artist, key.Album, key.Track}
+	for i, field := range fields {
+		fields[i] = strings.ToLower(strings.TrimSpace(field))
+	}
+	return strings.Join(fields, "|")
+}
+
+// New returns an instance of the Enricher, performing lookups on LastFM using
+// the provided number of workers.
+func New(client *lastfm.Client, workers int, autocorrect bool) (enricher *Enricher) {
+	enricher = &Enricher{
+		album:   album.New(client, "", autocorrect),
+		artist:  artist.New(client, "", autocorrect),
+		track:   track.New(client, "", autocorrect),
+		Workers: workers,
+	}
+	return
+}
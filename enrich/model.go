@@ -0,0 +1,80 @@
+package enrich
+
+import (
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go/api/album"
+	"git.maych.in/thunderbottom/lastfm-go/api/artist"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+// Kind is the type of LastFM entity to look up.
+type Kind int
+
+// Kinds of entities supported by the Enricher.
+const (
+	KindArtist Kind = iota
+	KindAlbum
+	KindTrack
+)
+
+// Enricher represents a structure to fetch metadata for many entities from
+// LastFM concurrently.
+type Enricher struct {
+	album  *album.Album
+	artist *artist.Artist
+	track  *track.Track
+
+	// Workers is the number of concurrent lookups.
+	Workers int
+	// Progress, if set, is called after each result with the number of results
+	// delivered so far and the number of keys received so far. Calls are serialized.
+	Progress func(done, total int)
+}
+
+// Key identifies an entity to look up. Artist is required for all kinds, along
+// with Album or Track for the respective kinds, unless MBID is set.
+type Key struct {
+	Kind   Kind
+	Artist string
+	Album  string
+	Track  string
+	MBID   string
+}
+
+// Metadata is the metadata of an entity fetched from LastFM.
+type Metadata struct {
+	Name      string
+	Artist    string
+	Album     string
+	MBID      string
+	URL       string
+	Listeners int64
+	Playcount int64
+	Duration  time.Duration
+	Tags      []string
+	// Images maps image sizes (`small`, `medium`, `large`, ...) to their URLs.
+	Images  map[string]string
+	Summary string
+}
+
+// Result is the outcome of the lookup of a single Key.
+//
+// Index is the position of Key in the input, starting at 0. Repeated keys
+// are looked up once, and share the same Metadata.
+type Result struct {
+	Index    int
+	Key      Key
+	Metadata *Metadata
+	Err      error
+}
+
+// lookup is a unique key being looked up, along with the input
+// indices waiting for its result.
+type lookup struct {
+	key      Key
+	done     bool
+	metadata *Metadata
+	err      error
+	waiters  []int
+}
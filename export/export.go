@@ -0,0 +1,187 @@
+// Package export writes scrobbles, loved tracks and top charts from LastFM
+// to CSV, JSON Lines and `.scrobbler.log` files.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+const (
+	kindTrack = "track"
+	kindChart = "chart"
+)
+
+// WriteTrack writes a single track. Timestamps are always written in UTC,
+// except for the ColumnTimeLocal column.
+func (e *Exporter) WriteTrack(track user.RecentTrack) (err error) {
+	if err = e.start(kindTrack); err != nil {
+		return
+	}
+	track.Time = track.Time.UTC()
+
+	switch e.Format {
+	case FormatCSV:
+		row := make([]string, len(e.Columns))
+		for i, column := range e.Columns {
+			row[i] = e.trackColumn(track, column)
+		}
+		return e.csv.Write(row)
+	case FormatJSONLines:
+		return e.json.Encode(track)
+	case FormatScrobblerLog:
+		fields := []string{
+			clean(track.Artist),
+			clean(track.Album),
+			clean(track.Track),
+			"",
+			"0",
+			"L",
+			strconv.FormatInt(track.Time.Unix(), 10),
+			clean(track.Mbid),
+		}
+		_, err = io.WriteString(e.w, strings.Join(fields, "\t")+"\n")
+		return
+	}
+	return fmt.Errorf("export: unknown format %v", e.Format)
+}
+
+// WriteEntry writes a single chart entry.
+func (e *Exporter) WriteEntry(entry user.ChartEntry) (err error) {
+	if e.Format == FormatScrobblerLog {
+		return fmt.Errorf("export: chart entries cannot be written as .scrobbler.log")
+	}
+	if err = e.start(kindChart); err != nil {
+		return
+	}
+
+	switch e.Format {
+	case FormatCSV:
+		row := make([]string, len(e.Columns))
+		for i, column := range e.Columns {
+			row[i] = entryColumn(entry, column)
+		}
+		return e.csv.Write(row)
+	case FormatJSONLines:
+		return e.json.Encode(entry)
+	}
+	return fmt.Errorf("export: unknown format %v", e.Format)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (e *Exporter) Flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+	return nil
+}
+
+// start writes the header of the file before the first record, and ensures
+// tracks and chart entries are not mixed.
+func (e *Exporter) start(kind string) (err error) {
+	if e.started {
+		if kind != e.kind {
+			return fmt.Errorf("export: cannot write %v records after %v records", kind, e.kind)
+		}
+		return nil
+	}
+	e.started, e.kind = true, kind
+
+	switch e.Format {
+	case FormatCSV:
+		if len(e.Columns) == 0 {
+			e.Columns = DefaultTrackColumns
+			if kind == kindChart {
+				e.Columns = DefaultChartColumns
+			}
+		}
+		e.csv = csv.NewWriter(e.w)
+		header := make([]string, len(e.Columns))
+		for i, column := range e.Columns {
+			header[i] = string(column)
+		}
+		return e.csv.Write(header)
+	case FormatJSONLines:
+		e.json = json.NewEncoder(e.w)
+	case FormatScrobblerLog:
+		client := e.Client
+		if client == "" {
+			client = "lastfm-go"
+		}
+		_, err = io.WriteString(e.w, "#AUDIOSCROBBLER/1.1\n#TZ/UTC\n#CLIENT/"+client+"\n")
+	}
+	return
+}
+
+func (e *Exporter) trackColumn(track user.RecentTrack, column Column) string {
+	switch column {
+	case ColumnTimestamp:
+		return strconv.FormatInt(track.Time.Unix(), 10)
+	case ColumnTimeUTC:
+		return track.Time.Format(time.RFC3339)
+	case ColumnTimeLocal:
+		loc := e.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		return track.Time.In(loc).Format(time.RFC3339)
+	case ColumnArtist:
+		return track.Artist
+	case ColumnArtistMbid:
+		return track.ArtistMbid
+	case ColumnAlbum:
+		return track.Album
+	case ColumnAlbumMbid:
+		return track.AlbumMbid
+	case ColumnTrack, ColumnName:
+		return track.Track
+	case ColumnMbid:
+		return track.Mbid
+	case ColumnURL:
+		return track.URL
+	case ColumnLoved:
+		return strconv.FormatBool(track.Loved)
+	}
+	return ""
+}
+
+func entryColumn(entry user.ChartEntry, column Column) string {
+	switch column {
+	case ColumnRank:
+		return strconv.Itoa(entry.Rank)
+	case ColumnName, ColumnTrack:
+		return entry.Name
+	case ColumnArtist:
+		return entry.Artist
+	case ColumnMbid:
+		return entry.Mbid
+	case ColumnURL:
+		return entry.URL
+	case ColumnPlaycount:
+		return strconv.FormatInt(entry.Playcount, 10)
+	}
+	return ""
+}
+
+// clean removes the tabs and line breaks which would break a `.scrobbler.log` line.
+func clean(s string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(s)
+}
+
+// New returns an instance of the Exporter writing to w in the provided format.
+// Flush must be called once all records are written.
+func New(w io.Writer, format Format) (exporter *Exporter) {
+	exporter = &Exporter{
+		Format: format,
+		w:      w,
+	}
+	return
+}
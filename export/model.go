@@ -0,0 +1,76 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Format is a file format supported by the Exporter.
+type Format int
+
+// Formats supported by the Exporter.
+const (
+	// FormatCSV writes RFC 4180 CSV with a header row.
+	FormatCSV Format = iota
+	// FormatJSONLines writes one JSON object per line.
+	FormatJSONLines
+	// FormatScrobblerLog writes the Audioscrobbler/Rockbox `.scrobbler.log`
+	// format. Only tracks can be written in this format.
+	FormatScrobblerLog
+)
+
+// Column is a CSV column written by the Exporter.
+type Column string
+
+// Columns available for tracks.
+const (
+	ColumnTimestamp  Column = "timestamp"
+	ColumnTimeUTC    Column = "time_utc"
+	ColumnTimeLocal  Column = "time_local"
+	ColumnArtist     Column = "artist"
+	ColumnArtistMbid Column = "artist_mbid"
+	ColumnAlbum      Column = "album"
+	ColumnAlbumMbid  Column = "album_mbid"
+	ColumnTrack      Column = "track"
+	ColumnMbid       Column = "mbid"
+	ColumnURL        Column = "url"
+	ColumnLoved      Column = "loved"
+)
+
+// Columns available for chart entries, along with ColumnArtist,
+// ColumnMbid and ColumnURL.
+const (
+	ColumnRank      Column = "rank"
+	ColumnName      Column = "name"
+	ColumnPlaycount Column = "playcount"
+)
+
+// DefaultTrackColumns are the CSV columns written for tracks when no
+// Columns are set.
+var DefaultTrackColumns = []Column{ColumnTimeUTC, ColumnArtist, ColumnAlbum, ColumnTrack, ColumnMbid}
+
+// DefaultChartColumns are the CSV columns written for chart entries when no
+// Columns are set.
+var DefaultChartColumns = []Column{ColumnRank, ColumnArtist, ColumnName, ColumnPlaycount, ColumnMbid, ColumnURL}
+
+// Exporter represents a structure to write tracks and chart entries from
+// LastFM to a file, one record at a time.
+type Exporter struct {
+	csv     *csv.Writer
+	json    *json.Encoder
+	kind    string
+	started bool
+	w       io.Writer
+
+	// Columns are the CSV columns to write, defaulting to
+	// DefaultTrackColumns or DefaultChartColumns.
+	Columns []Column
+	// Format is the file format written.
+	Format Format
+	// Location is the time zone of the ColumnTimeLocal column. Defaults to UTC.
+	Location *time.Location
+	// Client is the client name written to `.scrobbler.log` files.
+	Client string
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+// RecentTracks writes the tracks listened to by the user between from and
+// to, in unixtime, fetching and writing one page at a time. The track
+// currently playing is skipped. It returns the number of tracks written.
+func (e *Exporter) RecentTracks(u *user.User, from, to int64) (n int, err error) {
+	for page := 1; ; page++ {
+		rt, err := u.GetRecentTracksRange(false, from, to, page)
+		if err != nil {
+			return n, err
+		}
+		for _, track := range rt.List() {
+			if track.NowPlaying {
+				continue
+			}
+			if err = e.WriteTrack(track); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if page >= rt.TotalPages() {
+			return n, e.Flush()
+		}
+	}
+}
+
+// LovedTracks writes the tracks loved by the user, fetching and writing one
+// page at a time. It returns the number of tracks written.
+func (e *Exporter) LovedTracks(u *user.User) (n int, err error) {
+	for page := 1; ; page++ {
+		lt, err := u.GetLovedTracks(page)
+		if err != nil {
+			return n, err
+		}
+		for _, track := range lt.List() {
+			if err = e.WriteTrack(track); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if page >= lt.TotalPages() {
+			return n, e.Flush()
+		}
+	}
+}
+
+// TopAlbums writes the top albums of the user for period, fetching and
+// writing one page at a time. It returns the number of albums written.
+func (e *Exporter) TopAlbums(u *user.User, period user.Period) (n int, err error) {
+	return e.entries(func(page int) ([]user.ChartEntry, int, error) {
+		ta, err := u.GetTopAlbumsPeriod(period, page)
+		return ta.List(), ta.TotalPages(), err
+	})
+}
+
+// TopArtists writes the top artists of the user for period, fetching and
+// writing one page at a time. It returns the number of artists written.
+func (e *Exporter) TopArtists(u *user.User, period user.Period) (n int, err error) {
+	return e.entries(func(page int) ([]user.ChartEntry, int, error) {
+		ta, err := u.GetTopArtistsPeriod(period, page)
+		return ta.List(), ta.TotalPages(), err
+	})
+}
+
+// TopTracks writes the top tracks of the user for period, fetching and
+// writing one page at a time. It returns the number of tracks written.
+func (e *Exporter) TopTracks(u *user.User, period user.Period) (n int, err error) {
+	return e.entries(func(page int) ([]user.ChartEntry, int, error) {
+		tt, err := u.GetTopTracksPeriod(period, page)
+		return tt.List(), tt.TotalPages(), err
+	})
+}
+
+func (e *Exporter) entries(fetch func(page int) ([]user.ChartEntry, int, error)) (n int, err error) {
+	for page := 1; ; page++ {
+		list, pages, err := fetch(page)
+		if err != nil {
+			return n, err
+		}
+		for _, entry := range list {
+			if err = e.WriteEntry(entry); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if page >= pages {
+			return n, e.Flush()
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package lastfm
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrTimeout is reported for FanOut targets which did not respond in time.
+var ErrTimeout = errors.New("lastfm: scrobbler did not respond in time")
+
+// FanOutError is returned by FanOut when submitting to some targets failed.
+type FanOutError struct {
+	Outcomes []Outcome
+}
+
+func (e *FanOutError) Error() string {
+	var failed []string
+	for _, outcome := range e.Outcomes {
+		if outcome.Err != nil {
+			failed = append(failed, outcome.Target+": "+outcome.Err.Error())
+		}
+	}
+	return "scrobbling failed for " + strings.Join(failed, "; ")
+}
+
+// NowPlayingAll updates the track playing on every target, and returns the
+// outcome for each target in the order of the targets.
+func (f *FanOut) NowPlayingAll(scrobble Scrobble) []Outcome {
+	return f.run(func(s Scrobbler) ([]ScrobbleResult, error) {
+		return nil, s.NowPlaying(scrobble)
+	})
+}
+
+// ScrobbleAll submits the scrobbles to every target, and returns the outcome
+// for each target in the order of the targets.
+func (f *FanOut) ScrobbleAll(scrobbles []Scrobble) []Outcome {
+	return f.run(func(s Scrobbler) ([]ScrobbleResult, error) {
+		return s.Scrobble(scrobbles)
+	})
+}
+
+// LoveAll marks the track as loved on every target, and returns the outcome
+// for each target in the order of the targets.
+func (f *FanOut) LoveAll(artist, track string) []Outcome {
+	return f.run(func(s Scrobbler) ([]ScrobbleResult, error) {
+		return nil, s.Love(artist, track)
+	})
+}
+
+// NowPlaying updates the track playing on every target. A *FanOutError is
+// returned if any target failed.
+func (f *FanOut) NowPlaying(scrobble Scrobble) error {
+	return outcomesError(f.NowPlayingAll(scrobble))
+}
+
+// Scrobble submits the scrobbles to every target. A scrobble is reported as
+// accepted when at least one target accepted it. A *FanOutError is returned
+// if any target failed.
+func (f *FanOut) Scrobble(scrobbles []Scrobble) (results []ScrobbleResult, err error) {
+	outcomes := f.ScrobbleAll(scrobbles)
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			continue
+		}
+		for i, result := range outcome.Results {
+			switch {
+			case i >= len(results):
+				results = append(results, result)
+			case !results[i].Accepted && result.Accepted:
+				results[i] = result
+			}
+		}
+	}
+	return results, outcomesError(outcomes)
+}
+
+// Love marks the track as loved on every target. A *FanOutError is returned
+// if any target failed.
+func (f *FanOut) Love(artist, track string) error {
+	return outcomesError(f.LoveAll(artist, track))
+}
+
+func (f *FanOut) run(submit func(Scrobbler) ([]ScrobbleResult, error)) []Outcome {
+	outcomes := make([]Outcome, len(f.targets))
+	done := make(chan int, len(f.targets))
+	for i, target := range f.targets {
+		go func(i int, target Target) {
+			results, err := submit(target.Scrobbler)
+			outcomes[i] = Outcome{Target: target.Name, Results: results, Err: err}
+			done <- i
+		}(i, target)
+	}
+
+	var timeout <-chan time.Time
+	if f.Timeout > 0 {
+		timer := time.NewTimer(f.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	finished := make([]bool, len(f.targets))
+	for range f.targets {
+		select {
+		case i := <-done:
+			finished[i] = true
+		case <-timeout:
+			result := make([]Outcome, len(f.targets))
+			for i, target := range f.targets {
+				result[i] = Outcome{Target: target.Name, Err: ErrTimeout}
+			}
+			// drain the targets which finished meanwhile, the others keep
+			// writing to their own slot of outcomes which is no longer read
+			for {
+				select {
+				case i := <-done:
+					finished[i] = true
+				default:
+					for i := range result {
+						if finished[i] {
+							result[i] = outcomes[i]
+						}
+					}
+					return result
+				}
+			}
+		}
+	}
+	return outcomes
+}
+
+func outcomesError(outcomes []Outcome) error {
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			return &FanOutError{Outcomes: outcomes}
+		}
+	}
+	return nil
+}
+
+// NewFanOut returns a FanOut submitting to the provided targets.
+func NewFanOut(targets ...Target) (fanout *FanOut) {
+	fanout = &FanOut{
+		targets: targets,
+	}
+	return
+}
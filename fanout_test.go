@@ -0,0 +1,105 @@
+package lastfm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubScrobbler accepts every scrobble after waiting for release, if set, or
+// fails with err.
+type stubScrobbler struct {
+	release chan struct{}
+	err     error
+}
+
+func (s *stubScrobbler) wait() error {
+	if s.release != nil {
+		<-s.release
+	}
+	return s.err
+}
+
+func (s *stubScrobbler) NowPlaying(scrobble Scrobble) error {
+	return s.wait()
+}
+
+func (s *stubScrobbler) Scrobble(scrobbles []Scrobble) ([]ScrobbleResult, error) {
+	if err := s.wait(); err != nil {
+		return nil, err
+	}
+	results := make([]ScrobbleResult, len(scrobbles))
+	for i := range results {
+		results[i].Accepted = true
+	}
+	return results, nil
+}
+
+func (s *stubScrobbler) Love(artist, track string) error {
+	return s.wait()
+}
+
+func TestFanOut(t *testing.T) {
+	failed := errors.New("failed")
+	slow := &stubScrobbler{release: make(chan struct{})}
+	t.Cleanup(func() { close(slow.release) })
+
+	f := NewFanOut(
+		Target{Name: "first", Scrobbler: &stubScrobbler{}},
+		Target{Name: "slow", Scrobbler: slow},
+		Target{Name: "failing", Scrobbler: &stubScrobbler{err: failed}},
+		Target{Name: "last", Scrobbler: &stubScrobbler{}},
+	)
+	f.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	outcomes := f.ScrobbleAll([]Scrobble{{Artist: "Cher", Track: "Believe"}})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited %v for the slow target", elapsed)
+	}
+
+	want := []struct {
+		target string
+		err    error
+	}{
+		{"first", nil},
+		{"slow", ErrTimeout},
+		{"failing", failed},
+		{"last", nil},
+	}
+	if len(outcomes) != len(want) {
+		t.Fatalf("got outcomes %+v", outcomes)
+	}
+	for i, w := range want {
+		got := outcomes[i]
+		if got.Target != w.target || got.Err != w.err {
+			t.Errorf("outcome %d: got %v, %v, want %v, %v", i, got.Target, got.Err, w.target, w.err)
+		}
+		if accepted := len(got.Results) == 1 && got.Results[0].Accepted; accepted != (w.err == nil) {
+			t.Errorf("%v: got results %+v", w.target, got.Results)
+		}
+	}
+}
+
+func TestFanOutScrobble(t *testing.T) {
+	failed := errors.New("failed")
+	f := NewFanOut(
+		Target{Name: "failing", Scrobbler: &stubScrobbler{err: failed}},
+		Target{Name: "ok", Scrobbler: &stubScrobbler{}},
+	)
+
+	// The scrobbles accepted by one target are reported as accepted, along
+	// with the failure of the other.
+	results, err := f.Scrobble([]Scrobble{{Artist: "Cher", Track: "Believe"}, {Artist: "Cher", Track: "Strong Enough"}})
+	if len(results) != 2 || !results[0].Accepted || !results[1].Accepted {
+		t.Errorf("got results %+v", results)
+	}
+	var fanOutErr *FanOutError
+	if !errors.As(err, &fanOutErr) || fanOutErr.Error() != "scrobbling failed for failing: failed" {
+		t.Errorf("got error %v", err)
+	}
+
+	if err := NewFanOut(Target{Name: "ok", Scrobbler: &stubScrobbler{}}).Love("Cher", "Believe"); err != nil {
+		t.Errorf("got %v, want no error", err)
+	}
+}
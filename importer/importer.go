@@ -0,0 +1,218 @@
+// Package importer converts listening histories exported from ListenBrainz
+// and Spotify to LastFM scrobbles.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+const defaultMinPlayed = 30 * time.Second
+
+// ListenBrainz reads a ListenBrainz listen export, either as a JSON array
+// or as JSON Lines, and returns a preview of the plays. MBIDs are taken from
+// the MusicBrainz mapping when present, or from the submitted metadata.
+func ListenBrainz(r io.Reader, opts Options) (preview *Preview, err error) {
+	var listens []listenBrainzListen
+	if err = decodeList(r, &listens, func(line []byte) error {
+		var listen listenBrainzListen
+		if err := json.Unmarshal(line, &listen); err != nil {
+			return err
+		}
+		listens = append(listens, listen)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for i, listen := range listens {
+		meta := listen.TrackMetadata
+		info := meta.AdditionalInfo
+		scrobble := lastfm.Scrobble{
+			Artist:      meta.ArtistName,
+			Track:       meta.TrackName,
+			Timestamp:   listen.ListenedAt,
+			Album:       meta.ReleaseName,
+			AlbumArtist: info.ReleaseArtist,
+			MBID:        firstOf(meta.MbidMapping.RecordingMbid, info.RecordingMbid),
+			TrackNumber: trackNumber(info.TrackNumber),
+		}
+		switch {
+		case info.DurationMs > 0:
+			scrobble.Duration = info.DurationMs / 1000
+		case info.Duration > 0:
+			scrobble.Duration = info.Duration
+		}
+		entries = append(entries, Entry{Index: i, Scrobble: scrobble})
+	}
+	return newPreview(entries, opts), nil
+}
+
+// Spotify reads a Spotify extended streaming history file and returns a
+// preview of the plays. Plays shorter than MinPlayed, and podcast episodes,
+// are excluded. Spotify records the end of each play, so the timestamp of
+// each scrobble is moved back by the play time.
+func Spotify(r io.Reader, opts Options) (preview *Preview, err error) {
+	var streams []spotifyStream
+	if err = json.NewDecoder(r).Decode(&streams); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for i, stream := range streams {
+		entry := Entry{Index: i}
+		if stream.MsPlayed != nil {
+			entry.Played = time.Duration(*stream.MsPlayed) * time.Millisecond
+			entry.PlayedKnown = true
+		}
+		ended, err := time.Parse(time.RFC3339, stream.Ts)
+		if err != nil {
+			return nil, fmt.Errorf("entry %v: invalid timestamp %q", i, stream.Ts)
+		}
+		entry.Scrobble = lastfm.Scrobble{
+			Artist:       stream.ArtistName,
+			Track:        stream.TrackName,
+			Timestamp:    ended.Add(-entry.Played).Unix(),
+			Album:        stream.AlbumName,
+			ChosenByUser: true,
+		}
+		if stream.TrackName == "" || stream.ArtistName == "" {
+			entry.Status = StatusNotMusic
+			entry.Reason = "not a music track"
+		}
+		entries = append(entries, entry)
+	}
+	return newPreview(entries, opts), nil
+}
+
+// Scrobbles returns the scrobbles of the included plays.
+func (p *Preview) Scrobbles() (scrobbles []lastfm.Scrobble) {
+	for _, entry := range p.Entries {
+		if entry.Status == StatusIncluded {
+			scrobbles = append(scrobbles, entry.Scrobble)
+		}
+	}
+	return
+}
+
+// Submit scrobbles the included plays to LastFM, and updates their status
+// with the outcome reported by LastFM.
+func (p *Preview) Submit(t *track.Track) (err error) {
+	results, err := t.ScrobbleAll(p.Scrobbles())
+
+	i := 0
+	for e := range p.Entries {
+		entry := &p.Entries[e]
+		if entry.Status != StatusIncluded {
+			continue
+		}
+		if i >= len(results) {
+			break
+		}
+		if results[i].Accepted {
+			entry.Status = StatusAccepted
+			p.Accepted++
+		} else {
+			entry.Status = StatusIgnored
+			entry.Reason = results[i].IgnoredMessage
+			p.Ignored++
+		}
+		i++
+	}
+	return
+}
+
+// newPreview checks each entry against the play time and the timestamps
+// accepted by LastFM.
+func newPreview(entries []Entry, opts Options) (preview *Preview) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	minPlayed := opts.MinPlayed
+	if minPlayed <= 0 {
+		minPlayed = defaultMinPlayed
+	}
+
+	preview = &Preview{}
+	for _, entry := range entries {
+		played := time.Unix(entry.Scrobble.Timestamp, 0)
+		switch {
+		case entry.Status != "":
+		case entry.Scrobble.Artist == "" || entry.Scrobble.Track == "":
+			entry.Status, entry.Reason = StatusNotMusic, "missing artist or track name"
+		case entry.PlayedKnown && entry.Played < minPlayed:
+			entry.Status, entry.Reason = StatusTooShort, fmt.Sprintf("played for %v, less than %v", entry.Played, minPlayed)
+		case now.Sub(played) > lastfm.MaxScrobbleAge:
+			entry.Status, entry.Reason = StatusTooOld, fmt.Sprintf("older than %v", lastfm.MaxScrobbleAge)
+		case played.After(now.Add(24 * time.Hour)):
+			entry.Status, entry.Reason = StatusTooNew, "timestamp is in the future"
+		default:
+			entry.Status = StatusIncluded
+		}
+		if entry.Status == StatusIncluded {
+			preview.Included++
+		} else {
+			preview.Excluded++
+		}
+		preview.Entries = append(preview.Entries, entry)
+	}
+	return
+}
+
+// decodeList decodes r as a JSON array into list, or calls line for each
+// line of r when it holds JSON Lines.
+func decodeList(r io.Reader, list interface{}, line func([]byte) error) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return json.Unmarshal(trimmed, list)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if err = line(text); err != nil {
+			return fmt.Errorf("line %v: %v", n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// trackNumber parses track numbers submitted either as numbers or strings.
+func trackNumber(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
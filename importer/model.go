@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// Status is the outcome of the preview of an imported play.
+type Status string
+
+// Outcomes of the preview of an imported play.
+const (
+	// StatusIncluded plays are submitted as scrobbles.
+	StatusIncluded Status = "included"
+	// StatusTooShort plays were not played long enough to count as scrobbles.
+	StatusTooShort Status = "too_short"
+	// StatusNotMusic plays are podcast episodes or other non-music items.
+	StatusNotMusic Status = "not_music"
+	// StatusTooOld plays are older than lastfm.MaxScrobbleAge.
+	StatusTooOld Status = "too_old"
+	// StatusTooNew plays are in the future.
+	StatusTooNew Status = "too_new"
+	// StatusAccepted plays were accepted by LastFM.
+	StatusAccepted Status = "accepted"
+	// StatusIgnored plays were ignored by LastFM.
+	StatusIgnored Status = "ignored"
+)
+
+// Options configures the conversion of imported plays to scrobbles.
+type Options struct {
+	// MinPlayed is the minimum play time for a play to count as a scrobble,
+	// when the play time is known. Defaults to 30 seconds.
+	MinPlayed time.Duration
+	// Now is the time used to check the age of plays. Defaults to the
+	// current time.
+	Now time.Time
+}
+
+// Entry is a single imported play.
+type Entry struct {
+	// Index is the position of the play in the imported file, starting at 0.
+	Index    int
+	Scrobble lastfm.Scrobble
+	// Played is the play time recorded in the imported file, if PlayedKnown.
+	Played      time.Duration
+	PlayedKnown bool
+	Status      Status
+	Reason      string
+}
+
+// Preview contains the imported plays and the outcome of each of them, to be
+// reviewed before submitting.
+type Preview struct {
+	Entries  []Entry
+	Included int
+	Excluded int
+	Accepted int
+	Ignored  int
+}
+
+type listenBrainzListen struct {
+	ListenedAt    int64 `json:"listened_at"`
+	TrackMetadata struct {
+		ArtistName     string `json:"artist_name"`
+		TrackName      string `json:"track_name"`
+		ReleaseName    string `json:"release_name"`
+		AdditionalInfo struct {
+			RecordingMbid string      `json:"recording_mbid"`
+			ReleaseMbid   string      `json:"release_mbid"`
+			DurationMs    int64       `json:"duration_ms"`
+			Duration      int64       `json:"duration"`
+			TrackNumber   interface{} `json:"tracknumber"`
+			ReleaseArtist string      `json:"release_artist_name"`
+		} `json:"additional_info"`
+		MbidMapping struct {
+			RecordingMbid string `json:"recording_mbid"`
+			ReleaseMbid   string `json:"release_mbid"`
+		} `json:"mbid_mapping"`
+	} `json:"track_metadata"`
+}
+
+type spotifyStream struct {
+	Ts         string `json:"ts"`
+	MsPlayed   *int64 `json:"ms_played"`
+	TrackName  string `json:"master_metadata_track_name"`
+	ArtistName string `json:"master_metadata_album_artist_name"`
+	AlbumName  string `json:"master_metadata_album_album_name"`
+}
@@ -0,0 +1,140 @@
+package lastfm
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoAvailableKeys is returned when every key in the pool is cooling down.
+var ErrNoAvailableKeys = errors.New("lastfm: no API keys available, all keys are cooling down")
+
+// KeyPair is a LastFM API key and its shared secret, used to build a key pool.
+//
+// Rate is the number of requests per second allowed for the key. A Rate <= 0
+// leaves the key unlimited.
+type KeyPair struct {
+	Key    string
+	Secret string
+	Rate   float64
+}
+
+// KeyHealth reports the state of a key in the pool of a Client.
+type KeyHealth struct {
+	// Key is the masked API key, only the last four characters are shown.
+	Key           string
+	Available     bool
+	CoolingUntil  time.Time
+	Requests      int64
+	Failures      int64
+	LastErrorCode int
+}
+
+type pooledKey struct {
+	KeyPair
+	limiter      *rateLimiter
+	coolingUntil time.Time
+	requests     int64
+	failures     int64
+	lastCode     int
+}
+
+type keyPool struct {
+	mu       sync.Mutex
+	cooldown time.Duration
+	keys     []*pooledKey
+	next     int
+}
+
+// SetKeyPool spreads GET requests of the Client across the provided keys in
+// round-robin order. A key returning error 26 (suspended API key) or 29 (rate
+// limit exceeded) is taken out of rotation for the cooldown duration and the
+// request is retried with the next available key.
+//
+// POST requests are always signed using the APIKey and APISecret of the Client,
+// since sessions are only valid for the key that issued them. Passing an empty
+// list disables the key pool.
+func (client *Client) SetKeyPool(keys []KeyPair, cooldown time.Duration) {
+	if len(keys) == 0 {
+		client.pool = nil
+		return
+	}
+	pool := &keyPool{cooldown: cooldown}
+	for _, key := range keys {
+		pool.keys = append(pool.keys, &pooledKey{
+			KeyPair: key,
+			limiter: newRateLimiter(key.Rate),
+		})
+	}
+	client.pool = pool
+}
+
+// KeyHealth returns the health of each key in the pool of the Client, in the
+// order the keys were provided to SetKeyPool.
+func (client *Client) KeyHealth() (health []KeyHealth) {
+	if client.pool == nil {
+		return
+	}
+	pool := client.pool
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range pool.keys {
+		health = append(health, KeyHealth{
+			Key:           maskKey(key.Key),
+			Available:     !now.Before(key.coolingUntil),
+			CoolingUntil:  key.coolingUntil,
+			Requests:      key.requests,
+			Failures:      key.failures,
+			LastErrorCode: key.lastCode,
+		})
+	}
+	return
+}
+
+// acquire returns the next key in rotation which is not cooling down and
+// has not been tried yet, or nil if no such key exists.
+func (pool *keyPool) acquire(tried map[*pooledKey]bool) *pooledKey {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	now := time.Now()
+	for i := 0; i < len(pool.keys); i++ {
+		key := pool.keys[(pool.next+i)%len(pool.keys)]
+		if tried[key] || now.Before(key.coolingUntil) {
+			continue
+		}
+		pool.next = (pool.next + i + 1) % len(pool.keys)
+		key.requests++
+		return key
+	}
+	return nil
+}
+
+// report records the result of a request made with key, and reports whether
+// the key has been taken out of rotation.
+func (pool *keyPool) report(key *pooledKey, code int, err error) (cooling bool) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if err == nil {
+		return false
+	}
+	key.failures++
+	key.lastCode = code
+	if code == 26 || code == 29 {
+		key.coolingUntil = time.Now().Add(pool.cooldown)
+		return true
+	}
+	return false
+}
+
+// maskKey hides all but the last four characters of an API key, so that it
+// can be logged or put in an error.
+func maskKey(key string) string {
+	if len(key) <= 4 {
+		return "..."
+	}
+	return "..." + key[len(key)-4:]
+}
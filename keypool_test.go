@@ -0,0 +1,142 @@
+package lastfm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keyServer is a stand-in API server failing the requests of some API keys
+// with a LastFM error code.
+type keyServer struct {
+	mu     sync.Mutex
+	keys   []string
+	errors map[string]int
+}
+
+func (s *keyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("api_key")
+	s.mu.Lock()
+	s.keys = append(s.keys, r.Method+" "+key)
+	code, failed := s.errors[key]
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if failed {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, `{"error":%d,"message":"failed"}`, code)
+		return
+	}
+	fmt.Fprint(w, `{}`)
+}
+
+func (s *keyServer) used() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used := strings.Join(s.keys, ",")
+	s.keys = nil
+	return used
+}
+
+func newKeyServer(t *testing.T, errors map[string]int) (*Client, *keyServer) {
+	s := &keyServer{errors: errors}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	client := NewWithService(Service{Name: "stub", BaseURL: srv.URL}, "mainkey0000", "mainsecret")
+	client.SetKeyPool([]KeyPair{
+		{Key: "suspended1111", Secret: "s1"},
+		{Key: "good2222", Secret: "s2"},
+		{Key: "good3333", Secret: "s3"},
+	}, time.Hour)
+	return &client, s
+}
+
+func get(client *Client) error {
+	var v struct{}
+	return client.Request(&Provider{Method: "artist.getInfo", Params: map[string]string{"artist": "Cher"}, Response: &v, Type: "GET"})
+}
+
+func TestKeyPoolFailover(t *testing.T) {
+	client, s := newKeyServer(t, map[string]int{"suspended1111": 26})
+
+	if err := get(client); err != nil {
+		t.Fatalf("got %v, want the request to fail over", err)
+	}
+	if got := s.used(); got != "GET suspended1111,GET good2222" {
+		t.Errorf("got keys %v", got)
+	}
+
+	// The suspended key is out of rotation, and the other keys take turns.
+	for i := 0; i < 3; i++ {
+		if err := get(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := s.used(); got != "GET good3333,GET good2222,GET good3333" {
+		t.Errorf("got keys %v", got)
+	}
+
+	health := client.KeyHealth()
+	if len(health) != 3 {
+		t.Fatalf("got health %+v", health)
+	}
+	if h := health[0]; h.Key != "...1111" || h.Available || h.Failures != 1 || h.LastErrorCode != 26 || h.Requests != 1 || h.CoolingUntil.IsZero() {
+		t.Errorf("got health %+v for the suspended key", h)
+	}
+	if h := health[1]; h.Key != "...2222" || !h.Available || h.Failures != 0 || h.Requests != 2 {
+		t.Errorf("got health %+v for a good key", h)
+	}
+}
+
+func TestKeyPoolExhausted(t *testing.T) {
+	client, s := newKeyServer(t, map[string]int{"suspended1111": 26, "good2222": 29, "good3333": 29})
+
+	err := get(client)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 29 {
+		t.Errorf("got %v, want the error of the last key", err)
+	}
+	if got := s.used(); got != "GET suspended1111,GET good2222,GET good3333" {
+		t.Errorf("got keys %v", got)
+	}
+	if err = get(client); err != ErrNoAvailableKeys {
+		t.Errorf("got %v with every key cooling down, want ErrNoAvailableKeys", err)
+	}
+	if got := s.used(); got != "" {
+		t.Errorf("got requests %v with every key cooling down", got)
+	}
+}
+
+func TestKeyPoolOtherErrors(t *testing.T) {
+	client, s := newKeyServer(t, map[string]int{"suspended1111": 6})
+
+	// Errors other than 26 and 29 are returned without trying other keys.
+	var apiErr *APIError
+	if err := get(client); !errors.As(err, &apiErr) || apiErr.Code != 6 {
+		t.Errorf("got %v, want error 6", err)
+	}
+	if got := s.used(); got != "GET suspended1111" {
+		t.Errorf("got keys %v", got)
+	}
+	if h := client.KeyHealth()[0]; !h.Available || h.Failures != 1 || h.LastErrorCode != 6 {
+		t.Errorf("got health %+v", h)
+	}
+}
+
+func TestKeyPoolPost(t *testing.T) {
+	client, s := newKeyServer(t, nil)
+	client.SetSessionKey("session")
+
+	// Write requests are signed using the key of the session.
+	if err := client.Request(&Provider{Method: "track.love", Params: map[string]string{"artist": "Cher", "track": "Believe"}, Type: "POST"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.used(); got != "POST mainkey0000" {
+		t.Errorf("got keys %v", got)
+	}
+}
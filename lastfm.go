@@ -23,20 +23,23 @@
 package lastfm
 
 import (
+	"context"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"time"
 )
 
-const (
-	apiBaseURL = "https://ws.audioscrobbler.com/2.0/"
-	authURL    = "https://www.last.fm/api/auth/"
-)
-
 // New returns an instance of the LastFM Client.
 // The instance also includes an HTTP client for querying the LastFM API, with timeout set to 10 seconds.
 // The page limit for requests is set to 50 by default, which can be changed using SetLimit
 func New(apiKey, apiSecret string) (client Client) {
+	return NewWithService(LastFM, apiKey, apiSecret)
+}
+
+// NewWithService returns an instance of the Client bound to the provided service, such as
+// LibreFM or a self-hosted server implementing the LastFM API.
+func NewWithService(service Service, apiKey, apiSecret string) (client Client) {
 	client = Client{
 		APIKey:    apiKey,
 		APISecret: apiSecret,
@@ -44,6 +47,7 @@ func New(apiKey, apiSecret string) (client Client) {
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		service: service,
 	}
 	return
 }
@@ -53,16 +57,98 @@ func New(apiKey, apiSecret string) (client Client) {
 // and the request Type. Optionally, the provider should also include an interface to Unmarshal the
 // request response.
 //
+// GET requests are spread across the keys set using SetKeyPool, if any.
+//
 // This function is usually called from functions abstracting the LastFM API.
 func (client *Client) Request(provider *Provider) (err error) {
-	req, err := http.NewRequest(provider.Type, apiBaseURL, nil)
+	return client.RequestContext(context.Background(), provider)
+}
+
+// RequestContext performs the request described by provider like Request, and aborts
+// waiting for the response once ctx is done.
+//
+// If coalescing is enabled using SetCoalesce, identical GET requests in flight at the
+// same time share a single call to the LastFM API. Each caller decodes the shared response
+// into its own provider Response, and cancelling ctx only stops the caller from waiting.
+func (client *Client) RequestContext(ctx context.Context, provider *Provider) (err error) {
+	if !client.service.Supports(provider.Method) {
+		return &UnsupportedError{Service: client.service.Name, Method: provider.Method}
+	}
+
+	var resp *response
+	if client.flight != nil && provider.Type == "GET" {
+		resp, err = client.flight.do(ctx, flightKey(provider), func() (*response, error) {
+			return client.send(context.Background(), provider)
+		})
+	} else {
+		resp, err = client.send(ctx, provider)
+	}
 	if err != nil {
 		return err
 	}
 
+	if provider.Response != nil {
+		err = client.parseResponse(resp, provider.Response)
+		if err != nil {
+			client.log(LevelError, "lastfm response could not be decoded",
+				"method", provider.Method,
+				"error", err.Error(),
+			)
+		}
+	}
+
+	return
+}
+
+// send performs the request using the key pool for GET requests when set, or the
+// APIKey of the Client otherwise.
+func (client *Client) send(ctx context.Context, provider *Provider) (resp *response, err error) {
+	if client.pool == nil || provider.Type != "GET" {
+		if err = client.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		resp, _, err = client.do(ctx, provider, client.APIKey, client.APISecret)
+		return
+	}
+
+	tried := map[*pooledKey]bool{}
+	for {
+		key := client.pool.acquire(tried)
+		if key == nil {
+			if err == nil {
+				err = ErrNoAvailableKeys
+			}
+			return nil, err
+		}
+		tried[key] = true
+		if err = key.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		var code int
+		resp, code, err = client.do(ctx, provider, key.Key, key.Secret)
+		if !client.pool.report(key, code, err) {
+			return
+		}
+		client.log(LevelWarn, "lastfm api key taken out of rotation",
+			"key", maskKey(key.Key),
+			"error_code", code,
+			"cooldown", client.pool.cooldown,
+		)
+	}
+}
+
+// do performs a single request using the provided API key and secret, and
+// returns the raw response along with the LastFM error code, if any.
+func (client *Client) do(ctx context.Context, provider *Provider, apiKey, apiSecret string) (resp *response, code int, err error) {
+	req, err := http.NewRequestWithContext(ctx, provider.Type, client.service.BaseURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	params := req.URL.Query()
 	params.Add("method", provider.Method)
-	params.Add("api_key", client.APIKey)
+	params.Add("api_key", apiKey)
 	for key, value := range provider.Params {
 		params.Add(key, value)
 	}
@@ -74,7 +160,7 @@ func (client *Client) Request(provider *Provider) (err error) {
 		if client.sessionKey != "" {
 			params.Add("sk", client.sessionKey)
 		}
-		signature := client.generateSignature(params)
+		signature := Signature(params, apiSecret)
 		params.Add("api_sig", signature)
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	}
@@ -83,18 +169,30 @@ func (client *Client) Request(provider *Provider) (err error) {
 		req.Header.Set("User-Agent", client.useragent)
 	}
 	req.URL.RawQuery = params.Encode()
-	resp, err := client.httpClient.Do(req)
+	start := time.Now()
+	httpResp, err := client.httpClient.Do(req)
 	if err != nil {
-		return err
+		client.logRequest(provider, params, 0, 0, time.Since(start), err)
+		return nil, 0, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return client.parseError(resp, provider)
+	defer httpResp.Body.Close()
+
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		client.logRequest(provider, params, httpResp.StatusCode, 0, time.Since(start), err)
+		return nil, 0, err
 	}
+	client.logBody(provider, body)
 
-	if provider.Response != nil {
-		err = client.parseResponse(resp, provider.Response)
+	resp = &response{
+		status:      httpResp.StatusCode,
+		contentType: httpResp.Header.Get("Content-Type"),
+		body:        body,
+	}
+	if resp.status != http.StatusOK {
+		code, err = client.parseError(resp, provider, apiKey)
 	}
+	client.logRequest(provider, params, resp.status, code, time.Since(start), err)
 
 	return
 }
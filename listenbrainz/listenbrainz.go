@@ -0,0 +1,159 @@
+// Package listenbrainz implements lastfm.Scrobbler for ListenBrainz and
+// other servers speaking the ListenBrainz protocol.
+package listenbrainz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// DefaultBaseURL is the base URL of the ListenBrainz API.
+const DefaultBaseURL = "https://api.listenbrainz.org"
+
+// maxListens is the maximum number of listens in a single submission.
+const maxListens = 1000
+
+// NowPlaying updates the track currently playing for the user.
+func (c *Client) NowPlaying(scrobble lastfm.Scrobble) error {
+	if scrobble.Artist == "" || scrobble.Track == "" {
+		return fmt.Errorf("Artist and Track name are mandatory to update now playing")
+	}
+	l := newListen(scrobble)
+	l.ListenedAt = 0
+	return c.post("/1/submit-listens", submission{ListenType: "playing_now", Payload: []listen{l}}, nil)
+}
+
+// Scrobble submits the scrobbles as listens. ListenBrainz accepts or rejects
+// a submission as a whole, so every scrobble is reported as accepted when
+// the submission succeeds.
+func (c *Client) Scrobble(scrobbles []lastfm.Scrobble) (results []lastfm.ScrobbleResult, err error) {
+	for start := 0; start < len(scrobbles); start += maxListens {
+		end := start + maxListens
+		if end > len(scrobbles) {
+			end = len(scrobbles)
+		}
+		sub := submission{ListenType: "import"}
+		if end-start == 1 {
+			sub.ListenType = "single"
+		}
+		for idx, scrobble := range scrobbles[start:end] {
+			if scrobble.Artist == "" || scrobble.Track == "" || scrobble.Timestamp <= 0 {
+				return results, fmt.Errorf("%v: Artist, Track, and Timestamp are mandatory for scrobbling", start+idx)
+			}
+			sub.Payload = append(sub.Payload, newListen(scrobble))
+		}
+		if err = c.post("/1/submit-listens", sub, nil); err != nil {
+			return results, err
+		}
+		for range sub.Payload {
+			results = append(results, lastfm.ScrobbleResult{Accepted: true})
+		}
+	}
+	return
+}
+
+// Love marks the recording matching artist and track as loved. The recording
+// is looked up in the MusicBrainz metadata of ListenBrainz.
+func (c *Client) Love(artist, track string) error {
+	params := url.Values{}
+	params.Set("artist_name", artist)
+	params.Set("recording_name", track)
+
+	var result lookup
+	if err := c.get("/1/metadata/lookup/?"+params.Encode(), &result); err != nil {
+		return err
+	}
+	if result.RecordingMbid == "" {
+		return fmt.Errorf("listenbrainz: no recording found for %q by %q", track, artist)
+	}
+	return c.post("/1/feedback/recording-feedback", feedback{RecordingMbid: result.RecordingMbid, Score: 1}, nil)
+}
+
+func (c *Client) get(path string, response interface{}) error {
+	req, err := http.NewRequest("GET", c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, response)
+}
+
+func (c *Client) post(path string, body interface{}, response interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, response)
+}
+
+func (c *Client) do(req *http.Request, response interface{}) error {
+	req.Header.Set("Authorization", "Token "+c.token)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var respErr apiError
+		if json.Unmarshal(data, &respErr) == nil && respErr.Error != "" {
+			return fmt.Errorf("listenbrainz: %v: %v", resp.StatusCode, respErr.Error)
+		}
+		return fmt.Errorf("listenbrainz: %v", resp.Status)
+	}
+	if response != nil {
+		return json.Unmarshal(data, response)
+	}
+	return nil
+}
+
+func newListen(scrobble lastfm.Scrobble) listen {
+	return listen{
+		ListenedAt: scrobble.Timestamp,
+		TrackMetadata: trackMetadata{
+			ArtistName:  scrobble.Artist,
+			TrackName:   scrobble.Track,
+			ReleaseName: scrobble.Album,
+			AdditionalInfo: additionalInfo{
+				DurationMs:        scrobble.Duration * 1000,
+				RecordingMbid:     scrobble.MBID,
+				ReleaseArtistName: scrobble.AlbumArtist,
+				SubmissionClient:  "lastfm-go",
+				TrackNumber:       scrobble.TrackNumber,
+			},
+		},
+	}
+}
+
+// New returns an instance of the ListenBrainz Client authenticated with the
+// user token. baseURL defaults to DefaultBaseURL when empty, and can point
+// to any server implementing the ListenBrainz API.
+func New(baseURL, token string) (client *Client) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client = &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		token: token,
+	}
+	return
+}
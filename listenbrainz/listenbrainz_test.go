@@ -0,0 +1,190 @@
+package listenbrainz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// request is a request received by the stand-in server.
+type request struct {
+	method string
+	path   string
+	auth   string
+	body   []byte
+}
+
+// newServer returns a Client for a stand-in ListenBrainz server, which
+// records the requests and answers them using respond.
+func newServer(t *testing.T, respond func(w http.ResponseWriter, r *http.Request)) (*Client, *[]request) {
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, r.Header.Get("Authorization"), body})
+		respond(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return New(srv.URL+"/", "token"), &requests
+}
+
+func ok(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, `{"status":"ok"}`)
+}
+
+func TestScrobble(t *testing.T) {
+	c, requests := newServer(t, ok)
+
+	scrobbles := []lastfm.Scrobble{{
+		Artist:      "Massive Attack",
+		Track:       "Teardrop",
+		Album:       "Mezzanine",
+		AlbumArtist: "Massive Attack",
+		MBID:        "a1b2",
+		Duration:    330,
+		TrackNumber: 3,
+		Timestamp:   1600000000,
+	}}
+	results, err := c.Scrobble(scrobbles)
+	if err != nil {
+		t.Fatalf("Scrobble: %v", err)
+	}
+	if len(results) != 1 || !results[0].Accepted {
+		t.Fatalf("got results %+v", results)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*requests))
+	}
+	req := (*requests)[0]
+	if req.method != "POST" || req.path != "/1/submit-listens" || req.auth != "Token token" {
+		t.Errorf("got %v %v with %q", req.method, req.path, req.auth)
+	}
+	var sub submission
+	if err = json.Unmarshal(req.body, &sub); err != nil {
+		t.Fatal(err)
+	}
+	if sub.ListenType != "single" || len(sub.Payload) != 1 {
+		t.Fatalf("got submission %+v", sub)
+	}
+	l := sub.Payload[0]
+	info := l.TrackMetadata.AdditionalInfo
+	if l.ListenedAt != 1600000000 || l.TrackMetadata.TrackName != "Teardrop" || l.TrackMetadata.ReleaseName != "Mezzanine" {
+		t.Errorf("got listen %+v", l)
+	}
+	if info.DurationMs != 330000 || info.RecordingMbid != "a1b2" || info.TrackNumber != 3 {
+		t.Errorf("got additional info %+v", info)
+	}
+}
+
+func TestScrobbleBatches(t *testing.T) {
+	c, requests := newServer(t, ok)
+
+	var scrobbles []lastfm.Scrobble
+	for i := 0; i < maxListens+1; i++ {
+		scrobbles = append(scrobbles, lastfm.Scrobble{Artist: "A", Track: "T", Timestamp: int64(1600000000 + i)})
+	}
+	results, err := c.Scrobble(scrobbles)
+	if err != nil {
+		t.Fatalf("Scrobble: %v", err)
+	}
+	if len(results) != len(scrobbles) {
+		t.Errorf("got %d results, want %d", len(results), len(scrobbles))
+	}
+
+	var types []string
+	for _, req := range *requests {
+		var sub submission
+		if err = json.Unmarshal(req.body, &sub); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, fmt.Sprintf("%v:%v", sub.ListenType, len(sub.Payload)))
+	}
+	if got := strings.Join(types, ","); got != "import:1000,single:1" {
+		t.Errorf("got submissions %v", got)
+	}
+}
+
+func TestNowPlaying(t *testing.T) {
+	c, requests := newServer(t, ok)
+
+	if err := c.NowPlaying(lastfm.Scrobble{Artist: "A", Track: "T", Timestamp: 1600000000}); err != nil {
+		t.Fatalf("NowPlaying: %v", err)
+	}
+	body := string((*requests)[0].body)
+	if !strings.Contains(body, `"listen_type":"playing_now"`) || strings.Contains(body, "listened_at") {
+		t.Errorf("got body %v", body)
+	}
+}
+
+func TestLove(t *testing.T) {
+	c, requests := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/1/metadata/lookup/" {
+			if r.URL.Query().Get("artist_name") == "A" {
+				fmt.Fprint(w, `{"recording_mbid":"c3d4"}`)
+				return
+			}
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		ok(w, r)
+	})
+
+	if err := c.Love("A", "T"); err != nil {
+		t.Fatalf("Love: %v", err)
+	}
+	if len(*requests) != 2 || (*requests)[1].path != "/1/feedback/recording-feedback" {
+		t.Fatalf("got requests %+v", *requests)
+	}
+	var fb feedback
+	if err := json.Unmarshal((*requests)[1].body, &fb); err != nil || fb.RecordingMbid != "c3d4" || fb.Score != 1 {
+		t.Errorf("got feedback %+v, %v", fb, err)
+	}
+
+	if err := c.Love("B", "T"); err == nil || !strings.Contains(err.Error(), "no recording found") {
+		t.Errorf("got %v, want a missing recording error", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{http.StatusBadRequest, `{"code":400,"error":"JSON document may only contain listen_type and payload"}`, "listenbrainz: 400: JSON document may only contain listen_type and payload"},
+		{http.StatusUnauthorized, `{"code":401,"error":"Invalid authorization token."}`, "listenbrainz: 401: Invalid authorization token."},
+		{http.StatusTooManyRequests, `{"code":429,"error":"Ratelimit exceeded"}`, "listenbrainz: 429: Ratelimit exceeded"},
+		{http.StatusInternalServerError, `<html>Internal Server Error</html>`, "listenbrainz: 500 Internal Server Error"},
+	}
+	for _, test := range tests {
+		c, _ := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			fmt.Fprint(w, test.body)
+		})
+		results, err := c.Scrobble([]lastfm.Scrobble{{Artist: "A", Track: "T", Timestamp: 1600000000}})
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%v: got %v, want %v", test.status, err, test.want)
+		}
+		if len(results) != 0 {
+			t.Errorf("%v: got results %+v for a failed submission", test.status, results)
+		}
+	}
+}
+
+func TestScrobbleInvalid(t *testing.T) {
+	c, requests := newServer(t, ok)
+
+	_, err := c.Scrobble([]lastfm.Scrobble{{Artist: "A", Track: "T"}})
+	if err == nil {
+		t.Fatal("expected an error for a scrobble without a timestamp")
+	}
+	if len(*requests) != 0 {
+		t.Errorf("invalid scrobble was submitted")
+	}
+}
@@ -0,0 +1,51 @@
+package listenbrainz
+
+import (
+	"net/http"
+)
+
+// Client is a client for the ListenBrainz API, implementing lastfm.Scrobbler.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+}
+
+type submission struct {
+	ListenType string   `json:"listen_type"`
+	Payload    []listen `json:"payload"`
+}
+
+type listen struct {
+	ListenedAt    int64         `json:"listened_at,omitempty"`
+	TrackMetadata trackMetadata `json:"track_metadata"`
+}
+
+type trackMetadata struct {
+	ArtistName     string         `json:"artist_name"`
+	TrackName      string         `json:"track_name"`
+	ReleaseName    string         `json:"release_name,omitempty"`
+	AdditionalInfo additionalInfo `json:"additional_info"`
+}
+
+type additionalInfo struct {
+	DurationMs        int64  `json:"duration_ms,omitempty"`
+	RecordingMbid     string `json:"recording_mbid,omitempty"`
+	ReleaseArtistName string `json:"release_artist_name,omitempty"`
+	SubmissionClient  string `json:"submission_client"`
+	TrackNumber       int    `json:"tracknumber,omitempty"`
+}
+
+type feedback struct {
+	RecordingMbid string `json:"recording_mbid"`
+	Score         int    `json:"score"`
+}
+
+type lookup struct {
+	RecordingMbid string `json:"recording_mbid"`
+}
+
+type apiError struct {
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,131 @@
+package lastfm
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LogLevel is the severity of a log record emitted by the Client.
+// The values match the levels used by log/slog.
+type LogLevel int
+
+// Log levels supported by the Client.
+const (
+	LevelDebug LogLevel = -4
+	LevelInfo  LogLevel = 0
+	LevelWarn  LogLevel = 4
+	LevelError LogLevel = 8
+)
+
+// debugBodyLimit is the number of response bytes logged in debug mode.
+const debugBodyLimit = 2048
+
+const redacted = "[REDACTED]"
+
+// Logger is a structured logger accepting a message followed by alternating
+// key-value pairs. A *slog.Logger satisfies this interface.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// sensitiveParams are the request parameters that are never written to the log.
+var sensitiveParams = map[string]bool{
+	"api_key":  true,
+	"api_sig":  true,
+	"password": true,
+	"sk":       true,
+	"token":    true,
+}
+
+// sensitiveBody matches session keys and tokens in raw XML and JSON responses.
+var sensitiveBody = regexp.MustCompile(`(<(key|token)>)[^<]*(</(key|token)>)|("(key|token)"\s*:\s*")[^"]*(")`)
+
+// SetLogger sets the structured logger used by the Client. Records below level
+// are discarded. A nil logger disables logging.
+func (client *Client) SetLogger(logger Logger, level LogLevel) {
+	client.logger = logger
+	client.logLevel = level
+}
+
+// SetDebug enables or disables dumping of raw response bodies to the logger.
+// The bodies are logged at LevelDebug and truncated to 2048 bytes.
+func (client *Client) SetDebug(debug bool) {
+	client.debug = debug
+}
+
+func (client *Client) log(level LogLevel, msg string, args ...interface{}) {
+	if client.logger == nil || level < client.logLevel {
+		return
+	}
+	switch {
+	case level >= LevelError:
+		client.logger.Error(msg, args...)
+	case level >= LevelWarn:
+		client.logger.Warn(msg, args...)
+	case level >= LevelInfo:
+		client.logger.Info(msg, args...)
+	default:
+		client.logger.Debug(msg, args...)
+	}
+}
+
+func (client *Client) logRequest(provider *Provider, params url.Values, status, code int, duration time.Duration, err error) {
+	args := []interface{}{
+		"method", provider.Method,
+		"type", provider.Type,
+		"params", redactParams(params),
+		"status", status,
+		"duration", duration,
+	}
+	if err != nil {
+		args = append(args, "error_code", code, "error", err.Error())
+		client.log(LevelError, "lastfm request failed", args...)
+		return
+	}
+	client.log(LevelInfo, "lastfm request", args...)
+}
+
+func (client *Client) logBody(provider *Provider, body []byte) {
+	if !client.debug {
+		return
+	}
+	truncated := len(body) > debugBodyLimit
+	if truncated {
+		body = body[:debugBodyLimit]
+	}
+	client.log(LevelDebug, "lastfm response body",
+		"method", provider.Method,
+		"body", redactBody(string(body)),
+		"truncated", truncated,
+	)
+}
+
+// redactParams returns an encoded representation of params with all
+// sensitive values replaced.
+func redactParams(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := params.Get(key)
+		if sensitiveParams[key] {
+			value = redacted
+		}
+		parts = append(parts, key+"="+value)
+	}
+	return strings.Join(parts, "&")
+}
+
+func redactBody(body string) string {
+	return sensitiveBody.ReplaceAllString(body, "${1}${5}"+redacted+"${3}${7}")
+}
@@ -0,0 +1,49 @@
+package lastfm
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type recordLogger struct{ lines []string }
+
+func (l *recordLogger) record(msg string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+
+func (l *recordLogger) Debug(msg string, args ...interface{}) { l.record(msg, args...) }
+func (l *recordLogger) Info(msg string, args ...interface{})  { l.record(msg, args...) }
+func (l *recordLogger) Warn(msg string, args ...interface{})  { l.record(msg, args...) }
+func (l *recordLogger) Error(msg string, args ...interface{}) { l.record(msg, args...) }
+
+func TestSuspendedKeyRedacted(t *testing.T) {
+	const key = "SECRETAPIKEY123"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"error":26,"message":"Suspended API key"}`)
+	}))
+	defer srv.Close()
+
+	logger := &recordLogger{}
+	client := NewWithService(Service{Name: "stub", BaseURL: srv.URL}, key, "secret")
+	client.SetLogger(logger, LevelDebug)
+	client.SetDebug(true)
+
+	var v struct{}
+	err := client.Request(&Provider{Method: "user.getinfo", Params: map[string]string{}, Response: &v, Type: "GET"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if strings.Contains(err.Error(), key) {
+		t.Errorf("error leaks the API key: %v", err)
+	}
+	for _, line := range logger.lines {
+		if strings.Contains(line, key) {
+			t.Errorf("log leaks the API key: %v", line)
+		}
+	}
+}
@@ -3,8 +3,57 @@ package lastfm
 import (
 	"encoding/xml"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+const (
+	// MaxScrobbleBatch is the maximum number of scrobbles accepted by LastFM in a single request.
+	MaxScrobbleBatch = 50
+	// MaxScrobbleAge is the age after which scrobbles are ignored by LastFM.
+	MaxScrobbleAge = 14 * 24 * time.Hour
+	// MaxDailyScrobbles is the number of scrobbles per day after which LastFM
+	// ignores scrobbles.
+	MaxDailyScrobbles = 2800
+)
+
+// IgnoredReason is the code LastFM returns in the ignoredMessage of a
+// scrobble it did not accept.
+type IgnoredReason int
+
+// Reasons for LastFM to ignore a scrobble.
+const (
+	IgnoredNone            IgnoredReason = 0
+	IgnoredArtist          IgnoredReason = 1
+	IgnoredTrack           IgnoredReason = 2
+	IgnoredTimestampTooOld IgnoredReason = 3
+	IgnoredTimestampTooNew IgnoredReason = 4
+	IgnoredDailyLimit      IgnoredReason = 5
+	// IgnoredFiltered is not returned by LastFM. It reports scrobbles which
+	// were filtered out before being submitted, such as by a rewrite rule.
+	IgnoredFiltered IgnoredReason = -1
+)
+
+func (r IgnoredReason) String() string {
+	switch r {
+	case IgnoredNone:
+		return "not ignored"
+	case IgnoredArtist:
+		return "artist ignored"
+	case IgnoredTrack:
+		return "track ignored"
+	case IgnoredTimestampTooOld:
+		return "timestamp too old"
+	case IgnoredTimestampTooNew:
+		return "timestamp too new"
+	case IgnoredDailyLimit:
+		return "daily scrobble limit exceeded"
+	case IgnoredFiltered:
+		return "filtered before submission"
+	}
+	return "ignored code " + strconv.Itoa(int(r))
+}
+
 type xmlBase struct {
 	XMLName xml.Name `xml:"lfm"`
 	Status  string   `xml:"status,attr"`
@@ -20,20 +69,50 @@ type Auth struct {
 
 // Error contains the error response generated by the LastFM API.
 type Error struct {
-	ErrorCode int    `json:"error"`
-	Message   string `json:"message"`
+	ErrorCode int    `json:"error" xml:"code,attr"`
+	Message   string `json:"message" xml:",chardata"`
+}
+
+// APIError is returned for the error responses of the LastFM API. Code is the
+// LastFM error code, as listed on https://www.last.fm/api/errorcodes.
+type APIError struct {
+	Code    int
+	Message string
+	text    string
+}
+
+func (e *APIError) Error() string {
+	return e.text
 }
 
 // Client is the LastFM client.
 type Client struct {
 	APIKey     string
 	APISecret  string
+	debug      bool
+	flight     *flightGroup
 	httpClient *http.Client
 	limit      int
+	limiter    *rateLimiter
+	logger     Logger
+	logLevel   LogLevel
+	pool       *keyPool
+	service    Service
 	sessionKey string
 	useragent  string
 }
 
+// Service describes a scrobbling service implementing the LastFM 2.0 API.
+//
+// Methods lists the API methods supported by the service, as in `track.scrobble`.
+// A nil list means every method is supported.
+type Service struct {
+	Name    string
+	BaseURL string
+	AuthURL string
+	Methods []string
+}
+
 // Provider contains details about a LastFM API request.
 //
 // This structure is usually used by functions abstracting the LastFM API.
@@ -58,3 +137,61 @@ type Scrobble struct {
 	AlbumArtist  string
 	Duration     int64
 }
+
+// SearchMatch is a single artist, album or track matching a search query.
+// Artist is empty for artists, and LastFM does not return listener counts
+// for albums.
+type SearchMatch struct {
+	Name      string `json:"name"`
+	Artist    string `json:"artist,omitempty"`
+	Mbid      string `json:"mbid,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Image     string `json:"image,omitempty"`
+	Listeners int64  `json:"listeners"`
+}
+
+// Image is an image of an artist, album or track in a LastFM response.
+type Image struct {
+	Text string `json:"#text"`
+	Size string `json:"size"`
+}
+
+// ScrobbleResult is the outcome of a single scrobble submitted to LastFM.
+//
+// IgnoredCode and IgnoredMessage describe why LastFM ignored the scrobble,
+// when Accepted is false.
+type ScrobbleResult struct {
+	Accepted       bool
+	IgnoredCode    IgnoredReason
+	IgnoredMessage string
+}
+
+// Scrobbler is a service accepting scrobbles, now playing updates and loved
+// tracks. The track package implements it for LastFM.
+type Scrobbler interface {
+	NowPlaying(scrobble Scrobble) error
+	Scrobble(scrobbles []Scrobble) ([]ScrobbleResult, error)
+	Love(artist, track string) error
+}
+
+// Target is a named Scrobbler used by FanOut.
+type Target struct {
+	Name      string
+	Scrobbler Scrobbler
+}
+
+// Outcome is the result of a FanOut submission for a single target.
+type Outcome struct {
+	Target  string
+	Results []ScrobbleResult
+	Err     error
+}
+
+// FanOut submits to several scrobbling services in parallel.
+//
+// Timeout bounds the time spent waiting for each target. Targets which take
+// longer are reported with ErrTimeout, and finish in the background.
+type FanOut struct {
+	targets []Target
+	Timeout time.Duration
+}
@@ -0,0 +1,229 @@
+package mpd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dial connects to MPD over network, which is either "tcp" or "unix", and
+// authenticates using password, if not empty.
+func Dial(network, address, password string) (c *Conn, err error) {
+	conn, err := net.DialTimeout(network, address, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c = &Conn{conn: conn, reader: bufio.NewReader(conn)}
+
+	greeting, err := c.reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "OK MPD ") {
+		conn.Close()
+		return nil, fmt.Errorf("mpd: unexpected greeting %q", strings.TrimSpace(greeting))
+	}
+	c.Version = strings.TrimSpace(strings.TrimPrefix(greeting, "OK MPD "))
+
+	if password != "" {
+		if _, err = c.Command("password", password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return
+}
+
+// Close closes the connection to MPD.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Command sends a command with the provided arguments to MPD, and returns
+// the key-value pairs of the response in order.
+func (c *Conn) Command(name string, args ...string) (pairs [][2]string, err error) {
+	line := name
+	for _, arg := range args {
+		line += " " + quote(arg)
+	}
+	if _, err = fmt.Fprintf(c.conn, "%s\n", line); err != nil {
+		return nil, err
+	}
+	return c.readResponse()
+}
+
+// CurrentSong returns the song currently loaded by MPD. The returned Song is
+// empty when no song is loaded.
+func (c *Conn) CurrentSong() (song Song, err error) {
+	pairs, err := c.Command("currentsong")
+	if err != nil {
+		return
+	}
+	for _, pair := range pairs {
+		value := pair[1]
+		switch strings.ToLower(pair[0]) {
+		case "id":
+			song.ID = value
+		case "file":
+			song.File = value
+		case "artist":
+			if song.Artist == "" {
+				song.Artist = value
+			}
+		case "title":
+			song.Title = value
+		case "album":
+			song.Album = value
+		case "albumartist":
+			song.AlbumArtist = value
+		case "track":
+			// Track numbers are either "3" or "3/12".
+			song.TrackNumber, _ = strconv.Atoi(strings.SplitN(value, "/", 2)[0])
+		case "duration":
+			song.Duration = parseSeconds(value)
+		case "time":
+			if song.Duration == 0 {
+				song.Duration = parseSeconds(value)
+			}
+		case "musicbrainz_trackid":
+			song.MBID = value
+		case "musicbrainz_albumid":
+			song.AlbumMBID = value
+		case "musicbrainz_artistid":
+			song.ArtistMBID = value
+		}
+	}
+	return
+}
+
+// Status returns the player status of MPD.
+func (c *Conn) Status() (status Status, err error) {
+	pairs, err := c.Command("status")
+	if err != nil {
+		return
+	}
+	for _, pair := range pairs {
+		value := pair[1]
+		switch pair[0] {
+		case "state":
+			status.State = value
+		case "songid":
+			status.SongID = value
+		case "elapsed":
+			status.Elapsed = parseSeconds(value)
+		case "duration":
+			status.Duration = parseSeconds(value)
+		case "time":
+			// Older servers report "elapsed:total" in whole seconds.
+			parts := strings.SplitN(value, ":", 2)
+			if status.Elapsed == 0 {
+				status.Elapsed = parseSeconds(parts[0])
+			}
+			if len(parts) == 2 && status.Duration == 0 {
+				status.Duration = parseSeconds(parts[1])
+			}
+		}
+	}
+	return
+}
+
+// Idle waits until one of the provided subsystems changes, and returns the
+// changed subsystems. Idle returns no subsystems when interrupted by NoIdle.
+func (c *Conn) Idle(subsystems ...string) (changed []string, err error) {
+	c.mu.Lock()
+	line := strings.TrimSpace("idle " + strings.Join(subsystems, " "))
+	if _, err = fmt.Fprintf(c.conn, "%s\n", line); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.idling = true
+	c.mu.Unlock()
+
+	pairs, err := c.readResponse()
+
+	c.mu.Lock()
+	c.idling = false
+	c.mu.Unlock()
+	for _, pair := range pairs {
+		if pair[0] == "changed" {
+			changed = append(changed, pair[1])
+		}
+	}
+	return
+}
+
+// NoIdle interrupts a pending Idle call. It does nothing when the connection
+// is not idle.
+func (c *Conn) NoIdle() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.idling {
+		_, err = fmt.Fprint(c.conn, "noidle\n")
+	}
+	return
+}
+
+func (c *Conn) readResponse() (pairs [][2]string, err error) {
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "OK":
+			return pairs, nil
+		case strings.HasPrefix(line, "ACK "):
+			return nil, parseAck(line)
+		}
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("mpd: malformed response line %q", line)
+		}
+		pairs = append(pairs, [2]string{parts[0], parts[1]})
+	}
+}
+
+// parseAck parses an error response of the form
+// `ACK [code@index] {command} message`.
+func parseAck(line string) *Error {
+	e := &Error{Message: strings.TrimPrefix(line, "ACK ")}
+	var code, index int
+	var rest string
+	if n, _ := fmt.Sscanf(e.Message, "[%d@%d]", &code, &index); n == 2 {
+		e.Code = code
+		if i := strings.Index(e.Message, "] "); i >= 0 {
+			rest = e.Message[i+2:]
+		}
+		if strings.HasPrefix(rest, "{") {
+			if end := strings.Index(rest, "}"); end >= 0 {
+				e.Command = rest[1:end]
+				rest = strings.TrimSpace(rest[end+1:])
+			}
+		}
+		e.Message = rest
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("mpd: %v (error %v): %v", e.Command, e.Code, e.Message)
+}
+
+func quote(arg string) string {
+	arg = strings.Replace(arg, `\`, `\\`, -1)
+	arg = strings.Replace(arg, `"`, `\"`, -1)
+	return `"` + arg + `"`
+}
+
+func parseSeconds(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,82 @@
+package mpd
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// Conn represents a connection to a Music Player Daemon, speaking the MPD
+// text protocol.
+type Conn struct {
+	conn    net.Conn
+	idling  bool
+	mu      sync.Mutex
+	reader  *bufio.Reader
+	Version string
+}
+
+// Error is an ACK response returned by MPD for a failed command.
+type Error struct {
+	Code    int
+	Command string
+	Message string
+}
+
+// Song is the song currently loaded by MPD, as returned by `currentsong`.
+type Song struct {
+	ID          string
+	File        string
+	Artist      string
+	Title       string
+	Album       string
+	AlbumArtist string
+	TrackNumber int
+	Duration    time.Duration
+
+	// MusicBrainz identifiers of the recording, release and artist.
+	MBID       string
+	AlbumMBID  string
+	ArtistMBID string
+}
+
+// Status is the player status of MPD, as returned by `status`.
+type Status struct {
+	// State is one of "play", "pause" or "stop".
+	State    string
+	SongID   string
+	Elapsed  time.Duration
+	Duration time.Duration
+}
+
+// Scrobbler represents a structure to scrobble the songs played by MPD.
+type Scrobbler struct {
+	address  string
+	network  string
+	password string
+	target   lastfm.Scrobbler
+
+	// Logger receives submission and connection failures, if set.
+	Logger lastfm.Logger
+	// Now returns the current time. It defaults to time.Now, and can be
+	// replaced to control the timing rules.
+	Now func() time.Time
+	// Retry is the delay before reconnecting to MPD after a failure.
+	Retry time.Duration
+
+	current *play
+	pending []lastfm.Scrobble
+}
+
+// play tracks the time spent playing the current song.
+type play struct {
+	song        Song
+	start       time.Time
+	played      time.Duration
+	resumed     time.Time
+	lastElapsed time.Duration
+	scrobbled   bool
+}
@@ -0,0 +1,278 @@
+// Package mpd scrobbles the songs played by a Music Player Daemon.
+//
+// The Scrobbler follows the LastFM scrobbling rules: a song is scrobbled once
+// it has played for half its duration or for 4 minutes, whichever comes first,
+// and songs of 30 seconds or shorter are never scrobbled.
+package mpd
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+const (
+	// minDuration is the duration a song must exceed to be scrobbled.
+	minDuration = 30 * time.Second
+	// maxThreshold is the longest a song must play before it is scrobbled.
+	maxThreshold = 4 * time.Minute
+)
+
+// Scrobble returns the song as a LastFM Scrobble without a timestamp.
+func (song Song) Scrobble() lastfm.Scrobble {
+	return lastfm.Scrobble{
+		Artist:       song.Artist,
+		Track:        song.Title,
+		Album:        song.Album,
+		AlbumArtist:  song.AlbumArtist,
+		TrackNumber:  song.TrackNumber,
+		MBID:         song.MBID,
+		Duration:     int64(song.Duration / time.Second),
+		ChosenByUser: true,
+	}
+}
+
+// threshold returns the time the song must play before it is scrobbled, or
+// false if the song can not be scrobbled.
+func (song Song) threshold() (time.Duration, bool) {
+	if song.Artist == "" || song.Title == "" {
+		return 0, false
+	}
+	if song.Duration == 0 {
+		// Streams have no known duration.
+		return maxThreshold, true
+	}
+	if song.Duration <= minDuration {
+		return 0, false
+	}
+	if half := song.Duration / 2; half < maxThreshold {
+		return half, true
+	}
+	return maxThreshold, true
+}
+
+// Run connects to MPD and scrobbles the songs it plays until ctx is done,
+// reconnecting after failures.
+func (s *Scrobbler) Run(ctx context.Context) error {
+	for {
+		err := s.watch(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.log(lastfm.LevelWarn, "mpd connection failed", "address", s.address, "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.Retry):
+		}
+	}
+}
+
+func (s *Scrobbler) watch(ctx context.Context) error {
+	conn, err := Dial(s.network, s.address, s.password)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	for {
+		if err = s.Update(conn); err != nil {
+			return err
+		}
+
+		// Wake up when the current song reaches its scrobble threshold.
+		var timer *time.Timer
+		if remaining, ok := s.remaining(); ok {
+			timer = time.AfterFunc(remaining, func() { conn.NoIdle() })
+		}
+		_, err = conn.Idle("player")
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Update reads the player status and current song from conn, and submits
+// now playing updates and scrobbles accordingly. Run calls Update after every
+// change of the player.
+func (s *Scrobbler) Update(conn *Conn) error {
+	status, err := conn.Status()
+	if err != nil {
+		return err
+	}
+	song, err := conn.CurrentSong()
+	if err != nil {
+		return err
+	}
+	now := s.Now()
+
+	p := s.current
+	wasPlaying := p != nil && !p.resumed.IsZero()
+	if wasPlaying {
+		p.played += now.Sub(p.resumed)
+		p.resumed = time.Time{}
+	}
+
+	// A song restarting from the beginning after it qualified, such as when
+	// repeating, counts as a new play.
+	restarted := p != nil && p.song.ID == status.SongID && p.scrobbled &&
+		status.Elapsed < p.lastElapsed && status.Elapsed < minDuration
+	if p != nil && (status.State == "stop" || p.song.ID != status.SongID || restarted) {
+		s.finish(p)
+		p = nil
+	}
+	if p == nil && status.State != "stop" && song.ID != "" {
+		if song.Duration == 0 {
+			song.Duration = status.Duration
+		}
+		p = &play{song: song, start: now.Add(-status.Elapsed)}
+		wasPlaying = false
+	}
+	s.current = p
+	if p == nil {
+		return nil
+	}
+
+	p.lastElapsed = status.Elapsed
+	if status.State == "play" {
+		p.resumed = now
+		if !wasPlaying && !p.scrobbled {
+			s.nowPlaying(p.song)
+		}
+	}
+	if s.qualifies(p) {
+		s.scrobble(p)
+	}
+	return nil
+}
+
+// remaining returns the playing time left before the current song reaches
+// its scrobble threshold.
+func (s *Scrobbler) remaining() (time.Duration, bool) {
+	p := s.current
+	if p == nil || p.scrobbled || p.resumed.IsZero() {
+		return 0, false
+	}
+	threshold, ok := p.song.threshold()
+	if !ok {
+		return 0, false
+	}
+	remaining := threshold - p.played - s.Now().Sub(p.resumed)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+func (s *Scrobbler) qualifies(p *play) bool {
+	threshold, ok := p.song.threshold()
+	return ok && !p.scrobbled && p.played >= threshold
+}
+
+func (s *Scrobbler) finish(p *play) {
+	if s.qualifies(p) {
+		s.scrobble(p)
+	}
+}
+
+func (s *Scrobbler) nowPlaying(song Song) {
+	if _, ok := song.threshold(); !ok {
+		return
+	}
+	if err := s.target.NowPlaying(song.Scrobble()); err != nil {
+		s.log(lastfm.LevelWarn, "mpd now playing update failed", "artist", song.Artist, "track", song.Title, "error", err.Error())
+	}
+}
+
+// scrobble submits the play, along with the scrobbles which previously failed
+// with a retryable error. Scrobbles which LastFM ignored or rejected are
+// dropped, as resubmitting them can not succeed.
+func (s *Scrobbler) scrobble(p *play) {
+	p.scrobbled = true
+	scrobble := p.song.Scrobble()
+	scrobble.Timestamp = p.start.Unix()
+	s.pending = append(s.pending, scrobble)
+
+	if over := len(s.pending) - lastfm.MaxScrobbleBatch; over > 0 {
+		for _, dropped := range s.pending[:over] {
+			s.log(lastfm.LevelWarn, "mpd scrobble dropped", "artist", dropped.Artist, "track", dropped.Track, "reason", "too many pending scrobbles")
+		}
+		s.pending = s.pending[over:]
+	}
+
+	results, err := s.target.Scrobble(s.pending)
+	if err != nil {
+		if retryable(err) {
+			s.log(lastfm.LevelWarn, "mpd scrobble failed", "pending", len(s.pending), "error", err.Error())
+			return
+		}
+		for _, dropped := range s.pending {
+			s.log(lastfm.LevelWarn, "mpd scrobble dropped", "artist", dropped.Artist, "track", dropped.Track, "reason", err.Error())
+		}
+		s.pending = nil
+		return
+	}
+	for i, result := range results {
+		if i < len(s.pending) && !result.Accepted {
+			dropped := s.pending[i]
+			s.log(lastfm.LevelWarn, "mpd scrobble dropped", "artist", dropped.Artist, "track", dropped.Track, "reason", result.IgnoredCode.String())
+		}
+	}
+	s.pending = nil
+}
+
+// retryable reports whether a failed submission may succeed when it is sent
+// again: network failures, and LastFM errors for temporary outages and rate
+// limits.
+func retryable(err error) bool {
+	var apiErr *lastfm.APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	switch apiErr.Code {
+	case 8, 11, 16, 29:
+		return true
+	}
+	return false
+}
+
+func (s *Scrobbler) log(level lastfm.LogLevel, msg string, args ...interface{}) {
+	if s.Logger == nil {
+		return
+	}
+	switch level {
+	case lastfm.LevelWarn:
+		s.Logger.Warn(msg, args...)
+	default:
+		s.Logger.Info(msg, args...)
+	}
+}
+
+// New returns an instance of the Scrobbler for the MPD server at address on
+// network, which is either "tcp" or "unix". Songs are submitted to target,
+// such as the Scrobbler of the track API or a FanOut.
+func New(network, address, password string, target lastfm.Scrobbler) (scrobbler *Scrobbler) {
+	scrobbler = &Scrobbler{
+		address:  address,
+		network:  network,
+		password: password,
+		target:   target,
+		Now:      time.Now,
+		Retry:    10 * time.Second,
+	}
+	return
+}
@@ -0,0 +1,226 @@
+package mpd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// fakeMPD is a stand-in MPD server speaking the text protocol, answering
+// `status` and `currentsong` from its fields.
+type fakeMPD struct {
+	listener net.Listener
+	password string
+
+	mu     sync.Mutex
+	status []string
+	song   []string
+}
+
+func newFakeMPD(t *testing.T, password string) *fakeMPD {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeMPD{listener: listener, password: password}
+	t.Cleanup(func() { listener.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeMPD) set(status, song []string) {
+	f.mu.Lock()
+	f.status, f.song = status, song
+	f.mu.Unlock()
+}
+
+func (f *fakeMPD) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeMPD) handle(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprint(conn, "OK MPD 0.23.5\n")
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		f.mu.Lock()
+		var lines []string
+		switch fields[0] {
+		case "password":
+			if len(fields) != 2 || fields[1] != `"`+f.password+`"` {
+				f.mu.Unlock()
+				fmt.Fprint(conn, "ACK [3@0] {password} incorrect password\n")
+				continue
+			}
+		case "status":
+			lines = f.status
+		case "currentsong":
+			lines = f.song
+		}
+		f.mu.Unlock()
+		for _, l := range lines {
+			fmt.Fprintf(conn, "%s\n", l)
+		}
+		fmt.Fprint(conn, "OK\n")
+	}
+}
+
+// fakeTarget records the submissions, and fails scrobbles with the queued
+// errors.
+type fakeTarget struct {
+	nowPlaying []lastfm.Scrobble
+	scrobbles  [][]lastfm.Scrobble
+	errs       []error
+	ignored    map[string]lastfm.IgnoredReason
+}
+
+func (f *fakeTarget) NowPlaying(scrobble lastfm.Scrobble) error {
+	f.nowPlaying = append(f.nowPlaying, scrobble)
+	return nil
+}
+
+func (f *fakeTarget) Scrobble(scrobbles []lastfm.Scrobble) (results []lastfm.ScrobbleResult, err error) {
+	f.scrobbles = append(f.scrobbles, append([]lastfm.Scrobble(nil), scrobbles...))
+	if len(f.errs) > 0 {
+		err, f.errs = f.errs[0], f.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, scrobble := range scrobbles {
+		reason := f.ignored[scrobble.Track]
+		results = append(results, lastfm.ScrobbleResult{Accepted: reason == lastfm.IgnoredNone, IgnoredCode: reason})
+	}
+	return
+}
+
+func (f *fakeTarget) Love(artist, track string) error { return nil }
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func song(id, title string) []string {
+	return []string{"file: " + title + ".flac", "Id: " + id, "Artist: Artist", "Title: " + title, "Album: Album", "duration: 200.000"}
+}
+
+func playing(id string, elapsed int) []string {
+	return []string{"state: play", "songid: " + id, fmt.Sprintf("elapsed: %d.000", elapsed), "duration: 200.000"}
+}
+
+func setup(t *testing.T) (*fakeMPD, *Conn, *fakeTarget, *clock, *Scrobbler) {
+	server := newFakeMPD(t, "secret")
+	conn, err := Dial("tcp", server.listener.Addr().String(), "secret")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	target := &fakeTarget{}
+	c := &clock{now: time.Unix(1600000000, 0)}
+	s := New("tcp", server.listener.Addr().String(), "secret", target)
+	s.Now = c.Now
+	return server, conn, target, c, s
+}
+
+// update plays the song with id at elapsed seconds, and updates the Scrobbler.
+func update(t *testing.T, server *fakeMPD, conn *Conn, s *Scrobbler, id, title string, elapsed int) {
+	server.set(playing(id, elapsed), song(id, title))
+	if err := s.Update(conn); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+}
+
+func TestDialPassword(t *testing.T) {
+	server := newFakeMPD(t, "secret")
+	_, err := Dial("tcp", server.listener.Addr().String(), "wrong")
+	var mpdErr *Error
+	if !errors.As(err, &mpdErr) || mpdErr.Code != 3 || mpdErr.Command != "password" {
+		t.Fatalf("got %v, want a password ACK", err)
+	}
+}
+
+func TestScrobbleThreshold(t *testing.T) {
+	server, conn, target, c, s := setup(t)
+
+	update(t, server, conn, s, "1", "One", 0)
+	if len(target.nowPlaying) != 1 || target.nowPlaying[0].Track != "One" {
+		t.Fatalf("got now playing %+v, want One", target.nowPlaying)
+	}
+
+	c.now = c.now.Add(60 * time.Second)
+	update(t, server, conn, s, "1", "One", 60)
+	if len(target.scrobbles) != 0 {
+		t.Fatalf("scrobbled before the threshold: %+v", target.scrobbles)
+	}
+	if len(target.nowPlaying) != 1 {
+		t.Errorf("now playing sent again: %+v", target.nowPlaying)
+	}
+
+	c.now = c.now.Add(40 * time.Second)
+	update(t, server, conn, s, "1", "One", 100)
+	if len(target.scrobbles) != 1 || len(target.scrobbles[0]) != 1 {
+		t.Fatalf("got scrobbles %+v, want one", target.scrobbles)
+	}
+	if got := target.scrobbles[0][0]; got.Track != "One" || got.Timestamp != 1600000000 || got.Duration != 200 {
+		t.Errorf("got scrobble %+v", got)
+	}
+}
+
+func TestScrobblePending(t *testing.T) {
+	server, conn, target, c, s := setup(t)
+	target.errs = []error{
+		errors.New("connection reset"),
+		nil,
+		&lastfm.APIError{Code: 6, Message: "Invalid parameters"},
+	}
+	target.ignored = map[string]lastfm.IgnoredReason{"Two": lastfm.IgnoredArtist}
+
+	// The first play fails with a network error, and is kept.
+	update(t, server, conn, s, "1", "One", 0)
+	c.now = c.now.Add(100 * time.Second)
+	update(t, server, conn, s, "1", "One", 100)
+	if len(s.pending) != 1 {
+		t.Fatalf("got %d pending, want 1", len(s.pending))
+	}
+
+	// The next play is submitted along with the pending one. The ignored play
+	// is dropped and not sent again.
+	update(t, server, conn, s, "2", "Two", 0)
+	c.now = c.now.Add(100 * time.Second)
+	update(t, server, conn, s, "2", "Two", 100)
+	if len(target.scrobbles) != 2 || len(target.scrobbles[1]) != 2 {
+		t.Fatalf("got scrobbles %+v, want the pending play resent", target.scrobbles)
+	}
+	if len(s.pending) != 0 {
+		t.Fatalf("got %d pending after submitting, want 0", len(s.pending))
+	}
+
+	// A rejected play is dropped.
+	update(t, server, conn, s, "3", "Three", 0)
+	c.now = c.now.Add(100 * time.Second)
+	update(t, server, conn, s, "3", "Three", 100)
+	if len(target.scrobbles) != 3 || len(target.scrobbles[2]) != 1 {
+		t.Fatalf("got scrobbles %+v", target.scrobbles)
+	}
+	if len(s.pending) != 0 {
+		t.Fatalf("got %d pending after a rejection, want 0", len(s.pending))
+	}
+}
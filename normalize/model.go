@@ -0,0 +1,45 @@
+package normalize
+
+import (
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// Field is a text field of a scrobble.
+type Field string
+
+// Fields of a scrobble rules apply to.
+const (
+	FieldArtist      Field = "artist"
+	FieldTrack       Field = "track"
+	FieldAlbum       Field = "album"
+	FieldAlbumArtist Field = "album_artist"
+)
+
+// Rule is a normalization step applied to some fields of a scrobble.
+// Apply returns the normalized value of a field.
+type Rule struct {
+	Name   string
+	Fields []Field
+	Apply  func(field Field, value string) string
+}
+
+// Change is a field value changed by a rule.
+type Change struct {
+	Rule   string
+	Field  Field
+	Before string
+	After  string
+}
+
+// Result is a normalized scrobble, along with the original scrobble and the
+// changes made by each rule, in order.
+type Result struct {
+	Original lastfm.Scrobble
+	Scrobble lastfm.Scrobble
+	Changes  []Change
+}
+
+// Normalizer represents a structure to apply a pipeline of rules to scrobbles.
+type Normalizer struct {
+	rules []Rule
+}
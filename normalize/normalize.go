@@ -0,0 +1,128 @@
+// Package normalize cleans up the metadata of scrobbles from streaming
+// sources, so that variants such as "Song (Remastered 2011)" or
+// "Artist feat. Y" scrobble as, and look up, the same track on LastFM.
+package normalize
+
+import (
+	"strings"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// Scrobble applies the rules of the Normalizer to the scrobble in order, and
+// returns the result with every change made. A rule is never allowed to
+// clear a field.
+func (n *Normalizer) Scrobble(scrobble lastfm.Scrobble) (result Result) {
+	result.Original = scrobble
+	result.Scrobble = scrobble
+	for _, rule := range n.rules {
+		for _, field := range rule.Fields {
+			value := fieldValue(&result.Scrobble, field)
+			if value == nil || *value == "" {
+				continue
+			}
+			normalized := rule.Apply(field, *value)
+			if normalized == *value || normalized == "" {
+				continue
+			}
+			result.Changes = append(result.Changes, Change{
+				Rule:   rule.Name,
+				Field:  field,
+				Before: *value,
+				After:  normalized,
+			})
+			*value = normalized
+		}
+	}
+	return
+}
+
+// Track normalizes an artist name and track title, such as before looking
+// up the track using `track.GetInfo`.
+func (n *Normalizer) Track(artist, track string) (string, string, []Change) {
+	result := n.Scrobble(lastfm.Scrobble{Artist: artist, Track: track})
+	return result.Scrobble.Artist, result.Scrobble.Track, result.Changes
+}
+
+// Applied returns the names of the rules which changed the scrobble.
+func (result *Result) Applied() (rules []string) {
+	seen := map[string]bool{}
+	for _, change := range result.Changes {
+		if !seen[change.Rule] {
+			seen[change.Rule] = true
+			rules = append(rules, change.Rule)
+		}
+	}
+	return
+}
+
+// Featured returns the featured artists removed by SplitFeatured, in order
+// and without duplicates, so they can be kept by the caller.
+func (result *Result) Featured() (artists []string) {
+	seen := map[string]bool{}
+	for _, change := range result.Changes {
+		if change.Rule != SplitFeatured.Name {
+			continue
+		}
+		for _, artist := range featured(change.Before) {
+			key := strings.ToLower(artist)
+			if !seen[key] {
+				seen[key] = true
+				artists = append(artists, artist)
+			}
+		}
+	}
+	return
+}
+
+// Scrobbler returns a lastfm.Scrobbler normalizing now playing updates,
+// scrobbles and loved tracks before passing them to target.
+func (n *Normalizer) Scrobbler(target lastfm.Scrobbler) lastfm.Scrobbler {
+	return &scrobbler{normalizer: n, target: target}
+}
+
+type scrobbler struct {
+	normalizer *Normalizer
+	target     lastfm.Scrobbler
+}
+
+func (s *scrobbler) NowPlaying(scrobble lastfm.Scrobble) error {
+	return s.target.NowPlaying(s.normalizer.Scrobble(scrobble).Scrobble)
+}
+
+func (s *scrobbler) Scrobble(scrobbles []lastfm.Scrobble) ([]lastfm.ScrobbleResult, error) {
+	normalized := make([]lastfm.Scrobble, len(scrobbles))
+	for i, scrobble := range scrobbles {
+		normalized[i] = s.normalizer.Scrobble(scrobble).Scrobble
+	}
+	return s.target.Scrobble(normalized)
+}
+
+func (s *scrobbler) Love(artist, track string) error {
+	artist, track, _ = s.normalizer.Track(artist, track)
+	return s.target.Love(artist, track)
+}
+
+func fieldValue(scrobble *lastfm.Scrobble, field Field) *string {
+	switch field {
+	case FieldArtist:
+		return &scrobble.Artist
+	case FieldTrack:
+		return &scrobble.Track
+	case FieldAlbum:
+		return &scrobble.Album
+	case FieldAlbumArtist:
+		return &scrobble.AlbumArtist
+	}
+	return nil
+}
+
+// New returns an instance of the Normalizer applying the provided rules in
+// order, or DefaultRules when no rules are provided.
+func New(rules ...Rule) (normalizer *Normalizer) {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	normalizer = &Normalizer{rules: rules}
+	return
+}
@@ -0,0 +1,119 @@
+package normalize
+
+import (
+	"reflect"
+	"testing"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule  Rule
+		field Field
+		value string
+		want  string
+	}{
+		{UnifyPunctuation, FieldTrack, "Don’t Stop Me Now", "Don't Stop Me Now"},
+		{UnifyPunctuation, FieldArtist, "“Weird Al” Yankovic", `"Weird Al" Yankovic`},
+		{UnifyPunctuation, FieldAlbum, "Live 1975–85…", "Live 1975-85..."},
+		{UnifyPunctuation, FieldTrack, "Song Title", "Song Title"},
+
+		{TrimSpace, FieldArtist, "  Daft   Punk ", "Daft Punk"},
+		{TrimSpace, FieldTrack, "Get\tLucky", "Get Lucky"},
+
+		{StripRemaster, FieldTrack, "Here Comes the Sun (Remastered 2009)", "Here Comes the Sun"},
+		{StripRemaster, FieldTrack, "Heroes - 2017 Remaster", "Heroes"},
+		{StripRemaster, FieldAlbum, "Abbey Road [2019 Remaster]", "Abbey Road"},
+		{StripRemaster, FieldTrack, "Remaster", "Remaster"},
+
+		{StripEdition, FieldAlbum, "Random Access Memories (10th Anniversary Edition)", "Random Access Memories"},
+		{StripEdition, FieldAlbum, "Lover (Deluxe)", "Lover"},
+		{StripEdition, FieldAlbum, "Get Lucky - Single", "Get Lucky"},
+		{StripEdition, FieldAlbum, "Homework - EP", "Homework"},
+		{StripEdition, FieldAlbum, "The Singles", "The Singles"},
+
+		{StripExplicit, FieldTrack, "HUMBLE. [Explicit]", "HUMBLE."},
+		{StripExplicit, FieldAlbum, "DAMN. (Clean Version)", "DAMN."},
+
+		{SplitFeatured, FieldArtist, "Daft Punk feat. Pharrell Williams", "Daft Punk"},
+		{SplitFeatured, FieldArtist, "Calvin Harris (ft. Rihanna)", "Calvin Harris"},
+		{SplitFeatured, FieldArtist, "Featurecast", "Featurecast"},
+		{SplitFeatured, FieldTrack, "Get Lucky (feat. Pharrell Williams & Nile Rodgers)", "Get Lucky"},
+		{SplitFeatured, FieldTrack, "Get Lucky [Featuring Pharrell Williams] (Radio Edit)", "Get Lucky (Radio Edit)"},
+		{SplitFeatured, FieldTrack, "Lean On ft. MØ", "Lean On"},
+		{SplitFeatured, FieldTrack, "Señorita (with Camila Cabello)", "Señorita"},
+		{SplitFeatured, FieldTrack, "Dancing with Myself", "Dancing with Myself"},
+		// Artists are only split on "feat.", as "with" is part of names.
+		{SplitFeatured, FieldArtist, "Nick Cave with the Bad Seeds", "Nick Cave with the Bad Seeds"},
+
+		{StripLive, FieldTrack, "Wish You Were Here - Live at Knebworth", "Wish You Were Here"},
+		{StripLive, FieldAlbum, "Alive 2007 (Live)", "Alive 2007"},
+		{StripLive, FieldTrack, "Live Forever", "Live Forever"},
+	}
+	for _, test := range tests {
+		got := test.rule.Apply(test.field, test.value)
+		if got != test.want {
+			t.Errorf("%v(%v, %q) = %q, want %q", test.rule.Name, test.field, test.value, got, test.want)
+		}
+	}
+}
+
+func TestScrobble(t *testing.T) {
+	scrobble := lastfm.Scrobble{
+		Artist:    "Daft Punk feat. Pharrell Williams",
+		Track:     "Get Lucky (feat. Pharrell Williams & Nile Rodgers) - 2013 Remaster",
+		Album:     "Random Access Memories (Deluxe Edition)",
+		Timestamp: 1700000000,
+	}
+	result := New().Scrobble(scrobble)
+
+	want := lastfm.Scrobble{Artist: "Daft Punk", Track: "Get Lucky", Album: "Random Access Memories", Timestamp: 1700000000}
+	if result.Scrobble != want {
+		t.Errorf("got %+v, want %+v", result.Scrobble, want)
+	}
+	if result.Original != scrobble {
+		t.Errorf("got original %+v", result.Original)
+	}
+	changes := []Change{
+		{"strip_remaster", FieldTrack, "Get Lucky (feat. Pharrell Williams & Nile Rodgers) - 2013 Remaster", "Get Lucky (feat. Pharrell Williams & Nile Rodgers)"},
+		{"strip_edition", FieldAlbum, "Random Access Memories (Deluxe Edition)", "Random Access Memories"},
+		{"split_featured", FieldArtist, "Daft Punk feat. Pharrell Williams", "Daft Punk"},
+		{"split_featured", FieldTrack, "Get Lucky (feat. Pharrell Williams & Nile Rodgers)", "Get Lucky"},
+	}
+	if !reflect.DeepEqual(result.Changes, changes) {
+		t.Errorf("got changes %+v, want %+v", result.Changes, changes)
+	}
+	if got, want := result.Applied(), []string{"strip_remaster", "strip_edition", "split_featured"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got applied %v, want %v", got, want)
+	}
+	if got, want := result.Featured(), []string{"Pharrell Williams", "Nile Rodgers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got featured %v, want %v", got, want)
+	}
+}
+
+func TestScrobbleWith(t *testing.T) {
+	result := New().Scrobble(lastfm.Scrobble{Artist: "Shawn Mendes", Track: "Señorita (with Camila Cabello)"})
+	if result.Scrobble.Track != "Señorita" {
+		t.Errorf("got track %q", result.Scrobble.Track)
+	}
+	if got, want := result.Featured(), []string{"Camila Cabello"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got featured %v, want %v", got, want)
+	}
+}
+
+func TestScrobbleUnchanged(t *testing.T) {
+	// A rule clearing a field is not applied.
+	scrobble := lastfm.Scrobble{Artist: "Cher", Track: "(Remastered)", Album: "Believe"}
+	result := New().Scrobble(scrobble)
+	if result.Scrobble != scrobble || result.Changes != nil || result.Applied() != nil || result.Featured() != nil {
+		t.Errorf("got %+v", result)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	artist, track, changes := New(StripLive, TrimSpace).Track(" Oasis ", "Live Forever - Live at Knebworth")
+	if artist != "Oasis" || track != "Live Forever" || len(changes) != 2 {
+		t.Errorf("got %q, %q, %+v", artist, track, changes)
+	}
+}
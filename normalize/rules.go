@@ -0,0 +1,132 @@
+package normalize
+
+import (
+	"regexp"
+	"strings"
+)
+
+var allFields = []Field{FieldArtist, FieldTrack, FieldAlbum, FieldAlbumArtist}
+
+// punctuation maps typographic quotes, dashes and spaces to ASCII.
+var punctuation = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'", "´", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`,
+	"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "―", "-", "−", "-",
+	"…", "...",
+	" ", " ", " ", " ", " ", " ",
+)
+
+var (
+	spaces = regexp.MustCompile(`\s+`)
+
+	remaster = regexp.MustCompile(`(?i)\s*(` +
+		`[(\[][^)\]]*\bremaster(ed)?\b[^)\]]*[)\]]` +
+		`|\s-\s[^-]*\bremaster(ed)?\b.*$)`)
+	edition = regexp.MustCompile(`(?i)\s*(` +
+		`[(\[][^)\]]*\b(edition|deluxe|expanded|anniversary|bonus tracks?|special version)\b[^)\]]*[)\]]` +
+		`|\s-\s(single|ep)$)`)
+	explicit = regexp.MustCompile(`(?i)\s*[(\[](explicit|clean)( version)?[)\]]`)
+	live     = regexp.MustCompile(`(?i)\s*([(\[]live\b[^)\]]*[)\]]|\s-\slive\b.*$)`)
+
+	featuredArtist = regexp.MustCompile(`(?i)\s+([(\[]\s*)?(feat\.?|ft\.?|featuring)\s.*$`)
+	featuredTrack  = regexp.MustCompile(`(?i)\s*([(\[]\s*(feat\.?|ft\.?|featuring|with)\s[^)\]]*[)\]]|\s(feat\.?|ft\.?|featuring)\s.*$)`)
+	featuredNames  = regexp.MustCompile(`(?i)((^|[\s(\[])(feat\.?|ft\.?|featuring)|[(\[]\s*with)\s+([^)\]]*)`)
+	nameSeparator  = regexp.MustCompile(`\s*[,&]\s*`)
+)
+
+// Built-in rules.
+var (
+	// UnifyPunctuation replaces typographic quotes, dashes, ellipses and
+	// non-breaking spaces with their ASCII equivalents.
+	UnifyPunctuation = Rule{
+		Name:   "unify_punctuation",
+		Fields: allFields,
+		Apply: func(_ Field, value string) string {
+			return punctuation.Replace(value)
+		},
+	}
+
+	// TrimSpace collapses runs of whitespace and trims the ends of values.
+	TrimSpace = Rule{
+		Name:   "trim_space",
+		Fields: allFields,
+		Apply: func(_ Field, value string) string {
+			return strings.TrimSpace(spaces.ReplaceAllString(value, " "))
+		},
+	}
+
+	// StripRemaster removes remaster suffixes such as "(Remastered 2011)" or
+	// " - 2009 Remaster".
+	StripRemaster = Rule{
+		Name:   "strip_remaster",
+		Fields: []Field{FieldTrack, FieldAlbum},
+		Apply:  replacer(remaster),
+	}
+
+	// StripEdition removes album edition suffixes such as "(Deluxe Edition)"
+	// or " - Single".
+	StripEdition = Rule{
+		Name:   "strip_edition",
+		Fields: []Field{FieldAlbum},
+		Apply:  replacer(edition),
+	}
+
+	// StripExplicit removes "[Explicit]" and "(Clean)" markers.
+	StripExplicit = Rule{
+		Name:   "strip_explicit",
+		Fields: []Field{FieldTrack, FieldAlbum},
+		Apply:  replacer(explicit),
+	}
+
+	// SplitFeatured removes featured artists from artist names, such as
+	// "Artist feat. Y", and from track titles, such as "Song (feat. Y)" or
+	// "Song (with Y)".
+	// The removed artists are returned by Result.Featured.
+	SplitFeatured = Rule{
+		Name:   "split_featured",
+		Fields: []Field{FieldArtist, FieldTrack},
+		Apply: func(field Field, value string) string {
+			if field == FieldArtist {
+				return featuredArtist.ReplaceAllString(value, "")
+			}
+			return featuredTrack.ReplaceAllString(value, "")
+		},
+	}
+
+	// StripLive removes live suffixes such as " - Live at X" or "(Live)".
+	// Live recordings are usually distinct tracks on LastFM, so the rule is
+	// not part of DefaultRules.
+	StripLive = Rule{
+		Name:   "strip_live",
+		Fields: []Field{FieldTrack, FieldAlbum},
+		Apply:  replacer(live),
+	}
+)
+
+// DefaultRules are the rules applied by a Normalizer created without rules.
+var DefaultRules = []Rule{
+	UnifyPunctuation,
+	StripRemaster,
+	StripEdition,
+	StripExplicit,
+	SplitFeatured,
+	TrimSpace,
+}
+
+func replacer(re *regexp.Regexp) func(Field, string) string {
+	return func(_ Field, value string) string {
+		return re.ReplaceAllString(value, "")
+	}
+}
+
+// featured returns the artists named after "feat.", or "(with", in value.
+func featured(value string) (artists []string) {
+	for _, match := range featuredNames.FindAllStringSubmatch(value, -1) {
+		for _, name := range nameSeparator.Split(match[4], -1) {
+			if name = strings.TrimSpace(name); name != "" {
+				artists = append(artists, name)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package lastfm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces requests evenly so that no more than rate requests
+// are started per second.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait blocks until the next request slot is available, or ctx is done.
+// A nil rateLimiter never blocks.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetRateLimit limits the Client to rate requests per second made with its own
+// APIKey. A rate <= 0 removes the limit. Keys added using SetKeyPool carry
+// their own rate limits.
+func (client *Client) SetRateLimit(rate float64) {
+	client.limiter = newRateLimiter(rate)
+}
@@ -0,0 +1,112 @@
+package resolver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Get returns the correction cached for key.
+func (c *MemoryCache) Get(key string) (correction Correction, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	correction, ok = c.entries[key]
+	return
+}
+
+// Set caches the correction for key.
+func (c *MemoryCache) Set(key string, correction Correction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = correction
+	return nil
+}
+
+// Set caches the correction for key, and appends it to the file.
+func (c *FileCache) Set(key string, correction Correction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = correction
+
+	data, err := json.Marshal(fileEntry{Key: key, Correction: correction})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// compact rewrites the file with a single line for each entry.
+func (c *FileCache) compact() error {
+	var buf bytes.Buffer
+	for key, correction := range c.entries {
+		data, err := json.Marshal(fileEntry{Key: key, Correction: correction})
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() (cache *MemoryCache) {
+	cache = &MemoryCache{
+		entries: make(map[string]Correction),
+	}
+	return
+}
+
+// NewFileCache returns a FileCache stored at path, loading the corrections
+// already present in the file. The file is compacted when most of its lines
+// have been replaced by later ones.
+func NewFileCache(path string) (cache *FileCache, err error) {
+	cache = &FileCache{
+		MemoryCache: MemoryCache{entries: make(map[string]Correction)},
+		path:        path,
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries set again are appended, so later lines replace earlier ones.
+	lines := 0
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry fileEntry
+		if err = json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+		cache.entries[entry.Key] = entry.Correction
+		lines++
+	}
+	if lines > 2*len(cache.entries) {
+		if err = cache.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
@@ -0,0 +1,63 @@
+package resolver
+
+import (
+	"sync"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/artist"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+// Resolver represents a structure to canonicalize artist and track names
+// using the LastFM correction endpoints.
+type Resolver struct {
+	artist *artist.Artist
+	cache  Cache
+	track  *track.Track
+
+	// TTL is the duration cached corrections are valid for. Zero keeps
+	// cached corrections forever.
+	TTL time.Duration
+	// Logger receives cache write failures, if set.
+	Logger lastfm.Logger
+}
+
+// Correction contains the canonical names for an artist, or an artist and
+// track pair, as known by LastFM.
+//
+// When LastFM has no correction, Artist and Track equal the input and
+// Corrected is false.
+type Correction struct {
+	InputArtist string    `json:"input_artist"`
+	InputTrack  string    `json:"input_track,omitempty"`
+	Artist      string    `json:"artist"`
+	Track       string    `json:"track,omitempty"`
+	Corrected   bool      `json:"corrected"`
+	Checked     time.Time `json:"checked"`
+}
+
+// Cache stores corrections by key.
+type Cache interface {
+	Get(key string) (Correction, bool)
+	Set(key string, correction Correction) error
+}
+
+// MemoryCache is a Cache kept in memory.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]Correction
+}
+
+// FileCache is a Cache kept in memory and persisted as a JSON Lines file,
+// which each Set appends an entry to.
+type FileCache struct {
+	MemoryCache
+	path string
+}
+
+// fileEntry is a line of a FileCache file.
+type fileEntry struct {
+	Key        string     `json:"key"`
+	Correction Correction `json:"correction"`
+}
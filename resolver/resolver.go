@@ -0,0 +1,124 @@
+// Package resolver canonicalizes artist and track names using the LastFM
+// artist.getCorrection and track.getCorrection endpoints, and caches the
+// results.
+package resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/artist"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+// Artist returns the canonical name of the provided artist. The input is
+// returned when LastFM has no correction for the artist.
+func (r *Resolver) Artist(name string) (correction Correction, err error) {
+	// Names are cached as given, since Corrected depends on the casing of
+	// the input.
+	key := "artist\x00" + name
+	if correction, ok := r.cached(key); ok {
+		return correction, nil
+	}
+
+	correction = Correction{
+		InputArtist: name,
+		Artist:      name,
+		Checked:     time.Now(),
+	}
+	ac, err := r.artist.GetCorrection(name)
+	if err != nil && !noCorrection(err) {
+		return correction, err
+	}
+	if ac != nil && ac.Corrections.Correction.Artist.Name != "" {
+		correction.Artist = ac.Corrections.Correction.Artist.Name
+		correction.Corrected = correction.Artist != name
+	}
+	r.store(key, correction)
+	return correction, nil
+}
+
+// Track returns the canonical names of the provided artist and track. The
+// input is returned when LastFM has no correction for the track.
+func (r *Resolver) Track(artist, track string) (correction Correction, err error) {
+	key := "track\x00" + artist + "\x00" + track
+	if correction, ok := r.cached(key); ok {
+		return correction, nil
+	}
+
+	correction = Correction{
+		InputArtist: artist,
+		InputTrack:  track,
+		Artist:      artist,
+		Track:       track,
+		Checked:     time.Now(),
+	}
+	tc, err := r.track.GetCorrection(artist, track)
+	if err != nil && !noCorrection(err) {
+		return correction, err
+	}
+	if tc != nil {
+		c := tc.Corrections.Correction
+		if c.Track.Artist.Name != "" {
+			correction.Artist = c.Track.Artist.Name
+		}
+		if c.Track.Name != "" {
+			correction.Track = c.Track.Name
+		}
+		correction.Corrected = correction.Artist != artist || correction.Track != track
+	}
+	r.store(key, correction)
+	return correction, nil
+}
+
+// Scrobble returns a copy of scrobble with the artist and track names
+// replaced by their canonical names.
+func (r *Resolver) Scrobble(scrobble lastfm.Scrobble) (lastfm.Scrobble, error) {
+	correction, err := r.Track(scrobble.Artist, scrobble.Track)
+	if err != nil {
+		return scrobble, err
+	}
+	scrobble.Artist = correction.Artist
+	scrobble.Track = correction.Track
+	return scrobble, nil
+}
+
+func (r *Resolver) cached(key string) (correction Correction, ok bool) {
+	correction, ok = r.cache.Get(key)
+	if ok && r.TTL > 0 && time.Since(correction.Checked) > r.TTL {
+		return Correction{}, false
+	}
+	return
+}
+
+// noCorrection reports whether err was caused by LastFM returning an empty
+// `corrections` string instead of an object, which it does when there is no
+// correction available.
+func noCorrection(err error) bool {
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &typeErr) && typeErr.Field == "corrections" && typeErr.Value == "string"
+}
+
+// store caches the correction. A failure to cache is logged rather than
+// returned, since the correction itself is valid.
+func (r *Resolver) store(key string, correction Correction) {
+	if err := r.cache.Set(key, correction); err != nil && r.Logger != nil {
+		r.Logger.Warn("resolver cache write failed", "error", err.Error())
+	}
+}
+
+// New returns an instance of the Resolver, caching corrections in cache.
+// A MemoryCache is used when cache is nil.
+func New(client *lastfm.Client, cache Cache) (resolver *Resolver) {
+	if cache == nil {
+		cache = NewMemoryCache()
+	}
+	resolver = &Resolver{
+		artist: artist.New(client, "", false),
+		cache:  cache,
+		track:  track.New(client, "", false),
+	}
+	return
+}
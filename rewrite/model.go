@@ -0,0 +1,74 @@
+package rewrite
+
+import (
+	"regexp"
+	"sync"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// MatchType is the kind of pattern a Matcher uses.
+type MatchType string
+
+// Pattern kinds supported by a Matcher.
+const (
+	MatchExact MatchType = "exact"
+	MatchGlob  MatchType = "glob"
+	MatchRegex MatchType = "regex"
+)
+
+// Action is what a Rule does to the scrobbles it matches.
+type Action string
+
+// Actions of a Rule.
+const (
+	// ActionRewrite replaces field values, and continues evaluating rules.
+	ActionRewrite Action = "rewrite"
+	// ActionDrop discards the scrobble, including now playing updates.
+	ActionDrop Action = "drop"
+	// ActionNonScrobblable keeps now playing updates of the track, but never
+	// scrobbles it.
+	ActionNonScrobblable Action = "non_scrobblable"
+)
+
+// Matcher matches a field value against a pattern. Glob patterns support `*`
+// and `?` wildcards.
+type Matcher struct {
+	Type       MatchType `json:"type"`
+	Pattern    string    `json:"pattern"`
+	IgnoreCase bool      `json:"ignore_case,omitempty"`
+
+	mu sync.Mutex
+	re *regexp.Regexp
+}
+
+// Rule rewrites or filters the scrobbles matching all of its matchers. The
+// keys of Match and Set are the fields "artist", "track", "album" and
+// "album_artist".
+//
+// Values in Set may reference the submatches of a regex matcher on the same
+// field, such as `$1`. Other values are set as they are.
+type Rule struct {
+	Name     string              `json:"name"`
+	Disabled bool                `json:"disabled,omitempty"`
+	Match    map[string]*Matcher `json:"match"`
+	Action   Action              `json:"action"`
+	Set      map[string]string   `json:"set,omitempty"`
+}
+
+// RuleSet is an ordered list of rules, stored as a JSON file.
+type RuleSet struct {
+	path  string
+	Rules []Rule `json:"rules"`
+}
+
+// Result is the outcome of evaluating a RuleSet on a scrobble.
+type Result struct {
+	Original lastfm.Scrobble
+	Scrobble lastfm.Scrobble
+	// Action is the action of the rule which dropped the scrobble or marked
+	// it non-scrobblable, or ActionRewrite otherwise.
+	Action Action
+	// Fired are the names of the rules which matched, in order.
+	Fired []string
+}
@@ -0,0 +1,303 @@
+// Package rewrite implements user-defined rules which fix up, drop or block
+// scrobbles from mis-tagged sources before they are submitted to LastFM.
+//
+// Rule sets are stored as JSON:
+//
+//	{
+//	  "rules": [
+//	    {
+//	      "name": "beatles remaster",
+//	      "match": {"artist": {"type": "exact", "pattern": "The Beatles - Remastered"}},
+//	      "action": "rewrite",
+//	      "set": {"artist": "The Beatles"}
+//	    },
+//	    {
+//	      "name": "no podcasts",
+//	      "match": {"album": {"type": "glob", "pattern": "*Podcast*", "ignore_case": true}},
+//	      "action": "drop"
+//	    }
+//	  ]
+//	}
+//
+// Only JSON rule sets are supported; YAML files have to be converted to JSON
+// first.
+package rewrite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// compile validates the rule and compiles its patterns.
+func (rule *Rule) compile() error {
+	switch rule.Action {
+	case ActionRewrite:
+		if len(rule.Set) == 0 {
+			return fmt.Errorf("rule %q: rewrite rules must set at least one field", rule.Name)
+		}
+	case ActionDrop, ActionNonScrobblable:
+	default:
+		return fmt.Errorf("rule %q: unknown action %q", rule.Name, rule.Action)
+	}
+	if len(rule.Match) == 0 {
+		return fmt.Errorf("rule %q: no fields to match", rule.Name)
+	}
+	for field := range rule.Set {
+		if fieldValue(&lastfm.Scrobble{}, field) == nil {
+			return fmt.Errorf("rule %q: unknown field %q", rule.Name, field)
+		}
+	}
+
+	for field, m := range rule.Match {
+		if m == nil {
+			return fmt.Errorf("rule %q: empty matcher for %q", rule.Name, field)
+		}
+		if fieldValue(&lastfm.Scrobble{}, field) == nil {
+			return fmt.Errorf("rule %q: unknown field %q", rule.Name, field)
+		}
+		if m.Type == "" {
+			m.Type = MatchExact
+		}
+		if _, err := m.regexp(); err != nil {
+			return fmt.Errorf("rule %q: invalid pattern for %q: %v", rule.Name, field, err)
+		}
+	}
+	return nil
+}
+
+// regexp returns the compiled pattern of the matcher. Patterns are compiled
+// on first use, for matchers of rules which were not validated by Parse or
+// Add.
+func (m *Matcher) regexp() (*regexp.Regexp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.re != nil {
+		return m.re, nil
+	}
+	var expr string
+	switch m.Type {
+	case MatchExact, "":
+		expr = "^" + regexp.QuoteMeta(m.Pattern) + "$"
+	case MatchGlob:
+		expr = "^" + globExpr(m.Pattern) + "$"
+	case MatchRegex:
+		expr = m.Pattern
+	default:
+		return nil, fmt.Errorf("unknown match type %q", m.Type)
+	}
+	if m.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	m.re = re
+	return re, nil
+}
+
+// globExpr converts a glob pattern to a regular expression.
+func globExpr(pattern string) string {
+	var expr strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return expr.String()
+}
+
+// Apply evaluates the enabled rules in order on the scrobble. Rewrite rules
+// apply to the rewritten values of the previous rules, and evaluation stops
+// at the first rule dropping the scrobble or marking it non-scrobblable.
+func (rs *RuleSet) Apply(scrobble lastfm.Scrobble) (result Result) {
+	result = Result{Original: scrobble, Scrobble: scrobble, Action: ActionRewrite}
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		if rule.Disabled {
+			continue
+		}
+		submatches, ok := rule.match(&result.Scrobble)
+		if !ok {
+			continue
+		}
+		result.Fired = append(result.Fired, rule.Name)
+		if rule.Action != ActionRewrite {
+			result.Action = rule.Action
+			return
+		}
+		for field, value := range rule.Set {
+			if m, ok := rule.Match[field]; ok && m.Type == MatchRegex {
+				value = string(m.re.ExpandString(nil, value, *fieldValue(&result.Scrobble, field), submatches[field]))
+			}
+			*fieldValue(&result.Scrobble, field) = value
+		}
+	}
+	return
+}
+
+// match reports whether all matchers of the rule match the scrobble, along
+// with the submatch indexes of each field. A rule with an invalid matcher
+// never matches.
+func (rule *Rule) match(scrobble *lastfm.Scrobble) (submatches map[string][]int, ok bool) {
+	submatches = map[string][]int{}
+	for field, m := range rule.Match {
+		value := fieldValue(scrobble, field)
+		if m == nil || value == nil {
+			return nil, false
+		}
+		re, err := m.regexp()
+		if err != nil {
+			return nil, false
+		}
+		loc := re.FindStringSubmatchIndex(*value)
+		if loc == nil {
+			return nil, false
+		}
+		submatches[field] = loc
+	}
+	return submatches, true
+}
+
+// Scrobbler returns a lastfm.Scrobbler applying the rule set before passing
+// scrobbles to target. Dropped and non-scrobblable scrobbles are reported as
+// ignored in the results, with lastfm.IgnoredFiltered.
+func (rs *RuleSet) Scrobbler(target lastfm.Scrobbler) lastfm.Scrobbler {
+	return &scrobbler{rules: rs, target: target}
+}
+
+type scrobbler struct {
+	rules  *RuleSet
+	target lastfm.Scrobbler
+}
+
+func (s *scrobbler) NowPlaying(scrobble lastfm.Scrobble) error {
+	result := s.rules.Apply(scrobble)
+	if result.Action == ActionDrop {
+		return nil
+	}
+	return s.target.NowPlaying(result.Scrobble)
+}
+
+func (s *scrobbler) Scrobble(scrobbles []lastfm.Scrobble) ([]lastfm.ScrobbleResult, error) {
+	results := make([]lastfm.ScrobbleResult, len(scrobbles))
+	var submit []lastfm.Scrobble
+	var indexes []int
+	for i, scrobble := range scrobbles {
+		result := s.rules.Apply(scrobble)
+		if result.Action != ActionRewrite {
+			rule := result.Fired[len(result.Fired)-1]
+			results[i].IgnoredCode = lastfm.IgnoredFiltered
+			results[i].IgnoredMessage = fmt.Sprintf("%v by rule %q", result.Action, rule)
+			continue
+		}
+		submit = append(submit, result.Scrobble)
+		indexes = append(indexes, i)
+	}
+	if len(submit) == 0 {
+		return results, nil
+	}
+	submitted, err := s.target.Scrobble(submit)
+	for i, result := range submitted {
+		if i < len(indexes) {
+			results[indexes[i]] = result
+		}
+	}
+	return results, err
+}
+
+func (s *scrobbler) Love(artist, track string) error {
+	result := s.rules.Apply(lastfm.Scrobble{Artist: artist, Track: track})
+	if result.Action == ActionDrop {
+		return nil
+	}
+	return s.target.Love(result.Scrobble.Artist, result.Scrobble.Track)
+}
+
+// Add appends a rule to the rule set.
+func (rs *RuleSet) Add(rule Rule) error {
+	if err := rule.compile(); err != nil {
+		return err
+	}
+	rs.Rules = append(rs.Rules, rule)
+	return nil
+}
+
+// Save writes the rule set to the file it was loaded from, or created for.
+func (rs *RuleSet) Save() error {
+	if rs.path == "" {
+		return fmt.Errorf("rewrite: rule set was not loaded from a file")
+	}
+	data, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := rs.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, rs.path)
+}
+
+func fieldValue(scrobble *lastfm.Scrobble, field string) *string {
+	switch field {
+	case "artist":
+		return &scrobble.Artist
+	case "track":
+		return &scrobble.Track
+	case "album":
+		return &scrobble.Album
+	case "album_artist":
+		return &scrobble.AlbumArtist
+	}
+	return nil
+}
+
+// Parse reads a JSON rule set from r, and validates its rules.
+func Parse(r io.Reader) (rs *RuleSet, err error) {
+	rs = &RuleSet{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(rs); err != nil {
+		return nil, fmt.Errorf("rewrite: %v", err)
+	}
+	for i := range rs.Rules {
+		if err = rs.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("rewrite: %v", err)
+		}
+	}
+	return
+}
+
+// Load reads the rule set stored at path. A missing file is an error, which
+// can be checked with os.IsNotExist; use New to start a rule set instead.
+func Load(path string) (rs *RuleSet, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if rs, err = Parse(f); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	rs.path = path
+	return
+}
+
+// New returns an empty rule set, which is written to path by Save.
+func New(path string) (rs *RuleSet) {
+	rs = &RuleSet{path: path}
+	return
+}
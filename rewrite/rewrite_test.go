@@ -0,0 +1,238 @@
+package rewrite
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		err   string
+	}{
+		{"valid", `{"rules":[{"name":"r","match":{"artist":{"pattern":"A"}},"action":"drop"}]}`, ""},
+		{"unknown action", `{"rules":[{"name":"r","match":{"artist":{"pattern":"A"}},"action":"skip"}]}`, `unknown action "skip"`},
+		{"rewrite without set", `{"rules":[{"name":"r","match":{"artist":{"pattern":"A"}},"action":"rewrite"}]}`, "must set at least one field"},
+		{"no match", `{"rules":[{"name":"r","action":"drop"}]}`, "no fields to match"},
+		{"empty matcher", `{"rules":[{"name":"r","match":{"artist":null},"action":"drop"}]}`, `empty matcher for "artist"`},
+		{"unknown match field", `{"rules":[{"name":"r","match":{"genre":{"pattern":"A"}},"action":"drop"}]}`, `unknown field "genre"`},
+		{"unknown set field", `{"rules":[{"name":"r","match":{"artist":{"pattern":"A"}},"action":"rewrite","set":{"genre":"B"}}]}`, `unknown field "genre"`},
+		{"unknown match type", `{"rules":[{"name":"r","match":{"artist":{"type":"fuzzy","pattern":"A"}},"action":"drop"}]}`, `unknown match type "fuzzy"`},
+		{"invalid regex", `{"rules":[{"name":"r","match":{"artist":{"type":"regex","pattern":"("}},"action":"drop"}]}`, `invalid pattern for "artist"`},
+		{"unknown key", `{"rules":[],"version":2}`, `unknown field "version"`},
+		{"invalid json", `{"rules":`, "unexpected EOF"},
+	}
+	for _, test := range tests {
+		rs, err := Parse(strings.NewReader(test.rules))
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%v: %v", test.name, err)
+			} else if rs.Rules[0].Match["artist"].Type != MatchExact {
+				t.Errorf("%v: got match type %q, want the exact default", test.name, rs.Rules[0].Match["artist"].Type)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: got %v, want an error containing %q", test.name, err, test.err)
+		}
+	}
+}
+
+const rules = `{
+  "rules": [
+    {
+      "name": "remaster",
+      "match": {"artist": {"type": "exact", "pattern": "The Beatles - Remastered"}},
+      "action": "rewrite",
+      "set": {"artist": "The Beatles"}
+    },
+    {
+      "name": "disabled",
+      "disabled": true,
+      "match": {"artist": {"pattern": "The Beatles"}},
+      "action": "drop"
+    },
+    {
+      "name": "live",
+      "match": {"track": {"type": "regex", "pattern": "^(?P<title>.+) \\(Live\\)$"}},
+      "action": "rewrite",
+      "set": {"track": "${title}", "album": "$1 Live"}
+    },
+    {
+      "name": "price",
+      "match": {"track": {"type": "glob", "pattern": "*Dollar*", "ignore_case": true}},
+      "action": "rewrite",
+      "set": {"track": "$5 Dollars"}
+    },
+    {
+      "name": "podcasts",
+      "match": {"album": {"type": "glob", "pattern": "*podcast*", "ignore_case": true}},
+      "action": "drop"
+    },
+    {
+      "name": "jingles",
+      "match": {"artist": {"type": "glob", "pattern": "Radio ?"}, "track": {"type": "glob", "pattern": "Jingle*"}},
+      "action": "non_scrobblable"
+    },
+    {
+      "name": "after jingles",
+      "match": {"artist": {"type": "glob", "pattern": "Radio*"}},
+      "action": "rewrite",
+      "set": {"album_artist": "Radio"}
+    }
+  ]
+}`
+
+func TestApply(t *testing.T) {
+	rs, err := Parse(strings.NewReader(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in     lastfm.Scrobble
+		out    lastfm.Scrobble
+		action Action
+		fired  string
+	}{
+		{
+			lastfm.Scrobble{Artist: "The Beatles - Remastered", Track: "Help!"},
+			lastfm.Scrobble{Artist: "The Beatles", Track: "Help!"},
+			ActionRewrite, "remaster",
+		},
+		{
+			lastfm.Scrobble{Artist: "the beatles - remastered", Track: "Help!"},
+			lastfm.Scrobble{Artist: "the beatles - remastered", Track: "Help!"},
+			ActionRewrite, "",
+		},
+		{
+			// Only values for the field of the regex matcher are expanded.
+			lastfm.Scrobble{Artist: "Queen", Track: "Somebody to Love (Live)"},
+			lastfm.Scrobble{Artist: "Queen", Track: "Somebody to Love", Album: "$1 Live"},
+			ActionRewrite, "live",
+		},
+		{
+			// Replacements of glob and exact matchers are not expanded.
+			lastfm.Scrobble{Artist: "Pink Floyd", Track: "Money, Dollars"},
+			lastfm.Scrobble{Artist: "Pink Floyd", Track: "$5 Dollars"},
+			ActionRewrite, "price",
+		},
+		{
+			lastfm.Scrobble{Artist: "Host", Track: "Episode 1", Album: "The Podcast Show"},
+			lastfm.Scrobble{Artist: "Host", Track: "Episode 1", Album: "The Podcast Show"},
+			ActionDrop, "podcasts",
+		},
+		{
+			lastfm.Scrobble{Artist: "Radio 1", Track: "Jingle Bells"},
+			lastfm.Scrobble{Artist: "Radio 1", Track: "Jingle Bells"},
+			ActionNonScrobblable, "jingles",
+		},
+		{
+			lastfm.Scrobble{Artist: "Radio 10", Track: "Jingle Bells"},
+			lastfm.Scrobble{Artist: "Radio 10", Track: "Jingle Bells", AlbumArtist: "Radio"},
+			ActionRewrite, "after jingles",
+		},
+	}
+	for _, test := range tests {
+		result := rs.Apply(test.in)
+		if result.Scrobble != test.out || result.Action != test.action || strings.Join(result.Fired, ",") != test.fired {
+			t.Errorf("%+v: got %+v %v %v", test.in, result.Scrobble, result.Action, result.Fired)
+		}
+		if result.Original != test.in {
+			t.Errorf("%+v: got original %+v", test.in, result.Original)
+		}
+	}
+}
+
+func TestApplyUncompiled(t *testing.T) {
+	rs := &RuleSet{Rules: []Rule{
+		{Name: "invalid", Match: map[string]*Matcher{"artist": {Type: MatchRegex, Pattern: "("}}, Action: ActionDrop},
+		{Name: "literal", Match: map[string]*Matcher{"artist": {Pattern: "A"}}, Action: ActionRewrite, Set: map[string]string{"artist": "B"}},
+	}}
+	if result := rs.Apply(lastfm.Scrobble{Artist: "A", Track: "T"}); result.Scrobble.Artist != "B" || result.Action != ActionRewrite {
+		t.Errorf("got %+v for a rule set literal", result)
+	}
+
+	var decoded RuleSet
+	if err := json.Unmarshal([]byte(rules), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if result := decoded.Apply(lastfm.Scrobble{Artist: "The Beatles - Remastered", Track: "Help!"}); result.Scrobble.Artist != "The Beatles" {
+		t.Errorf("got %+v for a decoded rule set", result)
+	}
+}
+
+type target struct {
+	nowPlaying []lastfm.Scrobble
+	scrobbles  []lastfm.Scrobble
+	loved      []string
+}
+
+func (t *target) NowPlaying(scrobble lastfm.Scrobble) error {
+	t.nowPlaying = append(t.nowPlaying, scrobble)
+	return nil
+}
+
+func (t *target) Scrobble(scrobbles []lastfm.Scrobble) (results []lastfm.ScrobbleResult, err error) {
+	t.scrobbles = append(t.scrobbles, scrobbles...)
+	for range scrobbles {
+		results = append(results, lastfm.ScrobbleResult{Accepted: true})
+	}
+	return
+}
+
+func (t *target) Love(artist, track string) error {
+	t.loved = append(t.loved, artist+" - "+track)
+	return nil
+}
+
+func TestScrobbler(t *testing.T) {
+	rs, err := Parse(strings.NewReader(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt := &target{}
+	s := rs.Scrobbler(tgt)
+
+	results, err := s.Scrobble([]lastfm.Scrobble{
+		{Artist: "Host", Track: "Episode 1", Album: "Podcast"},
+		{Artist: "The Beatles - Remastered", Track: "Help!"},
+		{Artist: "Radio 1", Track: "Jingle"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tgt.scrobbles) != 1 || tgt.scrobbles[0].Artist != "The Beatles" {
+		t.Fatalf("got submitted %+v", tgt.scrobbles)
+	}
+	want := []struct {
+		accepted bool
+		code     lastfm.IgnoredReason
+		message  string
+	}{
+		{false, lastfm.IgnoredFiltered, `drop by rule "podcasts"`},
+		{true, lastfm.IgnoredNone, ""},
+		{false, lastfm.IgnoredFiltered, `non_scrobblable by rule "jingles"`},
+	}
+	for i, w := range want {
+		if results[i].Accepted != w.accepted || results[i].IgnoredCode != w.code || results[i].IgnoredMessage != w.message {
+			t.Errorf("result %d: got %+v", i, results[i])
+		}
+	}
+
+	// Now playing updates are sent for non-scrobblable tracks, but not for
+	// dropped ones.
+	s.NowPlaying(lastfm.Scrobble{Artist: "Host", Track: "Episode 1", Album: "Podcast"})
+	s.NowPlaying(lastfm.Scrobble{Artist: "Radio 1", Track: "Jingle"})
+	if len(tgt.nowPlaying) != 1 || tgt.nowPlaying[0].Artist != "Radio 1" {
+		t.Errorf("got now playing %+v", tgt.nowPlaying)
+	}
+
+	s.Love("The Beatles - Remastered", "Help!")
+	if len(tgt.loved) != 1 || tgt.loved[0] != "The Beatles - Help!" {
+		t.Errorf("got loved %v", tgt.loved)
+	}
+}
@@ -0,0 +1,78 @@
+package scrobblerlog
+
+import (
+	"time"
+)
+
+// Log is a parsed `.scrobbler.log` file.
+type Log struct {
+	Version string
+	// TZ is either `UTC` or `UNKNOWN`, when timestamps are in the local
+	// time of the device.
+	TZ      string
+	Client  string
+	Entries []Entry
+	// Errors are the lines which could not be parsed.
+	Errors []LineError
+}
+
+// LineError is a line of the log which could not be parsed.
+type LineError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+// Entry is a single play in a `.scrobbler.log` file.
+type Entry struct {
+	Line        int
+	Artist      string
+	Album       string
+	Track       string
+	TrackNumber int
+	Duration    int64
+	// Rating is `L` for listened tracks, or `S` for skipped tracks.
+	Rating    string
+	Timestamp int64
+	MBID      string
+}
+
+// Options configures the conversion of a Log to scrobbles.
+type Options struct {
+	// Location is the time zone of the device, used for logs with an
+	// `UNKNOWN` time zone. Defaults to UTC.
+	Location *time.Location
+	// Now is the time used to check the age of scrobbles. Defaults to the
+	// current time.
+	Now time.Time
+}
+
+// Status is the outcome of a line of the log.
+type Status string
+
+// Outcomes of the lines of a log.
+const (
+	StatusAccepted Status = "accepted"
+	StatusIgnored  Status = "ignored"
+	StatusSkipped  Status = "skipped"
+	StatusInvalid  Status = "invalid"
+	StatusPending  Status = "pending"
+)
+
+// LineResult is the outcome of a single line of the log.
+type LineResult struct {
+	Line    int
+	Entry   Entry
+	Status  Status
+	Reason  string
+	Warning string
+}
+
+// Report describes the outcome of the import of a log.
+type Report struct {
+	Accepted int
+	Ignored  int
+	Skipped  int
+	Invalid  int
+	Lines    []LineResult
+}
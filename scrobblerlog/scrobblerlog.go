@@ -0,0 +1,200 @@
+// Package scrobblerlog reads the `.scrobbler.log` files written by Rockbox
+// and other portable players, and submits their plays to LastFM.
+package scrobblerlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+// Parse reads a `.scrobbler.log` file. The `#AUDIOSCROBBLER/` header is
+// required, and lines which cannot be parsed are recorded in the Errors of
+// the log.
+func Parse(r io.Reader) (log *Log, err error) {
+	log = &Log{TZ: "UTC"}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "#AUDIOSCROBBLER/"):
+			log.Version = strings.TrimPrefix(text, "#AUDIOSCROBBLER/")
+			continue
+		case strings.HasPrefix(text, "#TZ/"):
+			log.TZ = strings.TrimPrefix(text, "#TZ/")
+			continue
+		case strings.HasPrefix(text, "#CLIENT/"):
+			log.Client = strings.TrimPrefix(text, "#CLIENT/")
+			continue
+		case strings.HasPrefix(text, "#"):
+			continue
+		}
+
+		entry, err := parseEntry(text)
+		if err != nil {
+			log.Errors = append(log.Errors, LineError{Line: line, Text: text, Err: err})
+			continue
+		}
+		entry.Line = line
+		log.Entries = append(log.Entries, entry)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if log.Version == "" {
+		return nil, fmt.Errorf("missing #AUDIOSCROBBLER header")
+	}
+	return
+}
+
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %v: %v", e.Line, e.Err)
+}
+
+func parseEntry(text string) (entry Entry, err error) {
+	fields := strings.Split(text, "\t")
+	if len(fields) < 7 {
+		return entry, fmt.Errorf("expected at least 7 tab-separated fields, found %v", len(fields))
+	}
+	entry = Entry{
+		Artist: fields[0],
+		Album:  fields[1],
+		Track:  fields[2],
+		Rating: strings.ToUpper(fields[5]),
+	}
+	if len(fields) > 7 {
+		entry.MBID = fields[7]
+	}
+	if entry.Artist == "" || entry.Track == "" {
+		return entry, fmt.Errorf("artist and track are required")
+	}
+	if fields[3] != "" {
+		if entry.TrackNumber, err = strconv.Atoi(fields[3]); err != nil {
+			return entry, fmt.Errorf("invalid track number %q", fields[3])
+		}
+	}
+	if fields[4] != "" {
+		if entry.Duration, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+			return entry, fmt.Errorf("invalid duration %q", fields[4])
+		}
+	}
+	if entry.Timestamp, err = strconv.ParseInt(fields[6], 10, 64); err != nil {
+		return entry, fmt.Errorf("invalid timestamp %q", fields[6])
+	}
+	if entry.Rating != "L" && entry.Rating != "S" {
+		return entry, fmt.Errorf("invalid rating %q", fields[5])
+	}
+	return entry, nil
+}
+
+// Scrobbles converts the entries of the log to scrobbles, and returns a
+// report with a line for every entry and every line which could not be
+// parsed, in line order. Skipped entries are left out of the scrobbles. Entries older than lastfm.MaxScrobbleAge or in the future are
+// converted, but carry a warning since LastFM will ignore them.
+//
+// The report lines of converted entries have StatusPending, and are in the
+// same order as the returned scrobbles.
+func (log *Log) Scrobbles(opts Options) (scrobbles []lastfm.Scrobble, report *Report) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	report = &Report{}
+	errs := log.Errors
+	for _, entry := range log.Entries {
+		for len(errs) > 0 && errs[0].Line < entry.Line {
+			report.invalid(errs[0])
+			errs = errs[1:]
+		}
+		result := LineResult{Line: entry.Line, Entry: entry, Status: StatusPending}
+		if entry.Rating == "S" {
+			result.Status = StatusSkipped
+			report.Skipped++
+			report.Lines = append(report.Lines, result)
+			continue
+		}
+
+		timestamp := entry.Timestamp
+		if strings.EqualFold(log.TZ, "UNKNOWN") {
+			timestamp = localToUTC(timestamp, opts.Location)
+		}
+		played := time.Unix(timestamp, 0)
+		switch {
+		case now.Sub(played) > lastfm.MaxScrobbleAge:
+			result.Warning = fmt.Sprintf("timestamp %v is older than %v and will be ignored", played.UTC(), lastfm.MaxScrobbleAge)
+		case played.After(now.Add(24 * time.Hour)):
+			result.Warning = fmt.Sprintf("timestamp %v is in the future and will be ignored", played.UTC())
+		}
+
+		scrobbles = append(scrobbles, lastfm.Scrobble{
+			Artist:       entry.Artist,
+			Track:        entry.Track,
+			Timestamp:    timestamp,
+			Album:        entry.Album,
+			ChosenByUser: true,
+			TrackNumber:  entry.TrackNumber,
+			MBID:         entry.MBID,
+			Duration:     entry.Duration,
+		})
+		report.Lines = append(report.Lines, result)
+	}
+	for _, lineErr := range errs {
+		report.invalid(lineErr)
+	}
+	return
+}
+
+func (report *Report) invalid(lineErr LineError) {
+	report.Invalid++
+	report.Lines = append(report.Lines, LineResult{Line: lineErr.Line, Status: StatusInvalid, Reason: lineErr.Err.Error()})
+}
+
+// Submit scrobbles the entries of the log to LastFM in batches of
+// lastfm.MaxScrobbleBatch, and returns the report of accepted, ignored and
+// skipped lines.
+func (log *Log) Submit(t *track.Track, opts Options) (report *Report, err error) {
+	scrobbles, report := log.Scrobbles(opts)
+	results, err := t.ScrobbleAll(scrobbles)
+
+	i := 0
+	for l := range report.Lines {
+		line := &report.Lines[l]
+		if line.Status != StatusPending {
+			continue
+		}
+		if i >= len(results) {
+			break
+		}
+		if results[i].Accepted {
+			line.Status = StatusAccepted
+			report.Accepted++
+		} else {
+			line.Status = StatusIgnored
+			line.Reason = results[i].IgnoredMessage
+			report.Ignored++
+		}
+		i++
+	}
+	return
+}
+
+// localToUTC converts a timestamp written in the wall clock time of loc
+// to unixtime.
+func localToUTC(timestamp int64, loc *time.Location) int64 {
+	if loc == nil {
+		return timestamp
+	}
+	wall := time.Unix(timestamp, 0).UTC()
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc).Unix()
+}
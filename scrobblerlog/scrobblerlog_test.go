@@ -0,0 +1,140 @@
+package scrobblerlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parse(t *testing.T, name string) *Log {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	log, err := Parse(f)
+	if err != nil {
+		t.Fatalf("%v: %v", name, err)
+	}
+	return log
+}
+
+func TestParse(t *testing.T) {
+	log := parse(t, "utc.log")
+	if log.Version != "1.1" || log.TZ != "UTC" || log.Client != "Rockbox ipodvideo $Revision$" {
+		t.Errorf("got header %q %q %q", log.Version, log.TZ, log.Client)
+	}
+
+	var tracks []string
+	for _, entry := range log.Entries {
+		tracks = append(tracks, entry.Track)
+	}
+	if got := strings.Join(tracks, ","); got != "Teardrop,Angel,Roads,Hyperballad" {
+		t.Fatalf("got entries %v", got)
+	}
+	want := Entry{
+		Line:        4,
+		Artist:      "Massive Attack",
+		Album:       "Mezzanine",
+		Track:       "Teardrop",
+		TrackNumber: 3,
+		Duration:    330,
+		Rating:      "L",
+		Timestamp:   1600000000,
+		MBID:        "10c9ec3c-4e11-4b6b-9c64-fd6b3fd5b1a3",
+	}
+	if log.Entries[0] != want {
+		t.Errorf("got entry %+v, want %+v", log.Entries[0], want)
+	}
+	if entry := log.Entries[1]; entry.Rating != "S" || entry.Line != 5 {
+		t.Errorf("got skipped entry %+v", entry)
+	}
+
+	if len(log.Errors) != 2 {
+		t.Fatalf("got errors %v, want 2", log.Errors)
+	}
+	if got := log.Errors[0]; got.Line != 7 || got.Text != "Broken line without tabs" || !strings.Contains(got.Error(), "line 7: expected at least 7") {
+		t.Errorf("got error %v", got)
+	}
+	if got := log.Errors[1]; got.Line != 8 || !strings.Contains(got.Error(), `invalid track number "one"`) {
+		t.Errorf("got error %v", got)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	log := parse(t, "unknown.log")
+	if log.Version != "1.1" || log.TZ != "UNKNOWN" || len(log.Entries) != 1 || log.Entries[0].MBID != "" {
+		t.Errorf("got %+v", log)
+	}
+
+	if _, err := Parse(strings.NewReader("#TZ/UTC\nA\tB\tC\t1\t100\tL\t1600000000\n")); err == nil {
+		t.Error("expected an error for a log without #AUDIOSCROBBLER header")
+	}
+}
+
+func TestScrobbles(t *testing.T) {
+	log := parse(t, "utc.log")
+	scrobbles, report := log.Scrobbles(Options{Now: time.Unix(1600100000, 0)})
+
+	var tracks []string
+	for _, s := range scrobbles {
+		tracks = append(tracks, s.Track)
+	}
+	if got := strings.Join(tracks, ","); got != "Teardrop,Roads,Hyperballad" {
+		t.Fatalf("got scrobbles %v", got)
+	}
+	if scrobbles[0].Timestamp != 1600000000 || !scrobbles[0].ChosenByUser || scrobbles[0].Duration != 330 {
+		t.Errorf("got scrobble %+v", scrobbles[0])
+	}
+
+	want := []struct {
+		line    int
+		status  Status
+		warning bool
+	}{
+		{4, StatusPending, false},
+		{5, StatusSkipped, false},
+		{6, StatusPending, true},
+		{7, StatusInvalid, false},
+		{8, StatusInvalid, false},
+		{9, StatusPending, false},
+	}
+	if len(report.Lines) != len(want) {
+		t.Fatalf("got report lines %+v", report.Lines)
+	}
+	for i, w := range want {
+		line := report.Lines[i]
+		if line.Line != w.line || line.Status != w.status || (line.Warning != "") != w.warning {
+			t.Errorf("line %d: got %+v", w.line, line)
+		}
+	}
+	if !strings.Contains(report.Lines[2].Warning, "older than") {
+		t.Errorf("got warning %q for an old timestamp", report.Lines[2].Warning)
+	}
+	if report.Lines[3].Reason == "" {
+		t.Error("invalid line has no reason")
+	}
+	if report.Skipped != 1 || report.Invalid != 2 {
+		t.Errorf("got %v skipped and %v invalid lines", report.Skipped, report.Invalid)
+	}
+}
+
+func TestScrobblesUnknownTZ(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	log := parse(t, "unknown.log")
+	scrobbles, _ := log.Scrobbles(Options{Location: loc, Now: time.Unix(1600100000, 0)})
+	// 2020-09-13 12:26:40 on the device clock, in New York daylight time.
+	if len(scrobbles) != 1 || scrobbles[0].Timestamp != 1600000000+4*3600 {
+		t.Errorf("got scrobbles %+v", scrobbles)
+	}
+
+	scrobbles, _ = log.Scrobbles(Options{Now: time.Unix(1600100000, 0)})
+	if scrobbles[0].Timestamp != 1600000000 {
+		t.Errorf("got timestamp %v without a location, want it unchanged", scrobbles[0].Timestamp)
+	}
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"git.maych.in/thunderbottom/lastfm-go/api/album"
+	"git.maych.in/thunderbottom/lastfm-go/api/artist"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+// Kind is the kind of entity a search result is.
+type Kind string
+
+// Kinds of entities searched.
+const (
+	KindArtist Kind = "artist"
+	KindAlbum  Kind = "album"
+	KindTrack  Kind = "track"
+)
+
+// Query is a free-text search. Kinds restricts the kinds of entities
+// searched, and defaults to all of them. Pages selects the page of results
+// fetched for each kind, and defaults to the first page.
+type Query struct {
+	Text  string
+	Kinds []Kind
+	Pages map[Kind]int
+}
+
+// Result is a single entity matching a query.
+type Result struct {
+	Kind      Kind   `json:"kind"`
+	Name      string `json:"name"`
+	Artist    string `json:"artist,omitempty"`
+	Mbid      string `json:"mbid,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Image     string `json:"image,omitempty"`
+	Listeners int64  `json:"listeners"`
+	// Score is the relevance of the result to the query, between 0 and 1.
+	Score float64 `json:"score"`
+}
+
+// Page describes the results of a single kind of entity.
+type Page struct {
+	Page         int   `json:"page"`
+	TotalPages   int   `json:"total_pages"`
+	TotalResults int   `json:"total_results"`
+	Err          error `json:"-"`
+}
+
+// Response contains the results of all kinds, ordered by decreasing
+// relevance, and the pagination of each kind.
+type Response struct {
+	Query   string        `json:"query"`
+	Results []Result      `json:"results"`
+	Pages   map[Kind]Page `json:"pages"`
+}
+
+// Searcher represents a structure to search artists, albums and tracks on
+// LastFM at once.
+type Searcher struct {
+	album  *album.Album
+	artist *artist.Artist
+	track  *track.Track
+}
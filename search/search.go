@@ -0,0 +1,254 @@
+// Package search searches artists, albums and tracks on LastFM concurrently,
+// and ranks the combined results by their relevance to a free-text query.
+package search
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/album"
+	"git.maych.in/thunderbottom/lastfm-go/api/artist"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+// popularityWeight is the share of the score given to the listener count.
+const popularityWeight = 0.1
+
+// ErrNoResults is returned by Best when nothing matches the query.
+var ErrNoResults = errors.New("search: no results")
+
+var allKinds = []Kind{KindArtist, KindAlbum, KindTrack}
+
+// Search runs the searches of each kind concurrently, and merges the results.
+// The search of a kind failing does not fail the others: its error is set in
+// the Page of the kind, and an error is only returned when all kinds fail.
+func (s *Searcher) Search(q Query) (resp *Response, err error) {
+	kinds := q.Kinds
+	if len(kinds) == 0 {
+		kinds = allKinds
+	}
+	resp = &Response{Query: q.Text, Pages: map[Kind]Page{}}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, kind := range kinds {
+		page := q.Pages[kind]
+		if page < 1 {
+			page = 1
+		}
+		wg.Add(1)
+		go func(kind Kind, page int) {
+			defer wg.Done()
+			matches, p := s.search(kind, q.Text, page)
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Pages[kind] = p
+			resp.Results = append(resp.Results, matches...)
+		}(kind, page)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, p := range resp.Pages {
+		if p.Err != nil {
+			failed++
+			err = p.Err
+		}
+	}
+	if failed < len(kinds) {
+		err = nil
+	}
+	for i := range resp.Results {
+		resp.Results[i].Score = score(q.Text, resp.Results[i])
+	}
+	sort.SliceStable(resp.Results, func(i, j int) bool {
+		a, b := resp.Results[i], resp.Results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Listeners > b.Listeners
+	})
+	return
+}
+
+// Best returns the most relevant result for the free-text query, across
+// all kinds, such as the track "Karma Police" by Radiohead for the query
+// "radiohead karma police".
+func (s *Searcher) Best(text string, kinds ...Kind) (best Result, err error) {
+	resp, err := s.Search(Query{Text: text, Kinds: kinds})
+	if err != nil {
+		return best, err
+	}
+	if len(resp.Results) == 0 {
+		return best, ErrNoResults
+	}
+	return resp.Results[0], nil
+}
+
+func (s *Searcher) search(kind Kind, text string, page int) (results []Result, p Page) {
+	p.Page = page
+	var matches []lastfm.SearchMatch
+	switch kind {
+	case KindArtist:
+		as, err := s.artist.Search(text, page)
+		if p.Err = err; err == nil {
+			matches = as.List()
+			p.TotalResults, p.TotalPages = as.Total(), as.TotalPages()
+		}
+	case KindAlbum:
+		as, err := s.album.Search("", text, page)
+		if p.Err = err; err == nil {
+			matches = as.List()
+			p.TotalResults, p.TotalPages = as.Total(), as.TotalPages()
+		}
+	case KindTrack:
+		ts, err := s.track.Search("", text, page)
+		if p.Err = err; err == nil {
+			matches = ts.List()
+			p.TotalResults, p.TotalPages = ts.Total(), ts.TotalPages()
+		}
+	}
+	for _, m := range matches {
+		results = append(results, Result{
+			Kind:      kind,
+			Name:      m.Name,
+			Artist:    m.Artist,
+			Mbid:      m.Mbid,
+			URL:       m.URL,
+			Image:     m.Image,
+			Listeners: m.Listeners,
+		})
+	}
+	return
+}
+
+// score rates the relevance of a result to the query, from the similarity
+// of the query to the name, or to the artist and name, of the result. The
+// listener count breaks ties between similar results.
+func score(text string, result Result) float64 {
+	query := normalize(text)
+	similarity := Similarity(query, normalize(result.Name))
+	if result.Artist != "" {
+		full := Similarity(query, normalize(result.Artist+" "+result.Name))
+		if full > similarity {
+			similarity = full
+		}
+	}
+	// Listener counts range up to the millions, and are scaled down to
+	// [0, 1] on a logarithmic scale.
+	popularity := math.Log10(float64(result.Listeners)+1) / 7
+	if popularity > 1 {
+		popularity = 1
+	}
+	return (1-popularityWeight)*similarity + popularityWeight*popularity
+}
+
+// Similarity returns the similarity of two strings between 0 and 1, as the
+// average of the Dice coefficient of their character bigrams, and of the
+// Jaccard index of their words. The words make the similarity insensitive
+// to word order, such as "karma police radiohead".
+func Similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if a == "" || b == "" {
+		return 0
+	}
+	return (dice(a, b) + jaccard(strings.Fields(a), strings.Fields(b))) / 2
+}
+
+func dice(a, b string) float64 {
+	bigrams := func(s string) map[string]int {
+		runes := []rune(strings.Replace(s, " ", "", -1))
+		grams := map[string]int{}
+		for i := 0; i+1 < len(runes); i++ {
+			grams[string(runes[i:i+2])]++
+		}
+		return grams
+	}
+	ga, gb := bigrams(a), bigrams(b)
+	var shared, total int
+	for gram, n := range ga {
+		total += n
+		if m := gb[gram]; m < n {
+			shared += m
+		} else {
+			shared += n
+		}
+	}
+	for _, n := range gb {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(shared) / float64(total)
+}
+
+func jaccard(a, b []string) float64 {
+	set := map[string]int{}
+	for _, w := range a {
+		set[w] |= 1
+	}
+	for _, w := range b {
+		set[w] |= 2
+	}
+	var both int
+	for _, v := range set {
+		if v == 3 {
+			both++
+		}
+	}
+	return float64(both) / float64(len(set))
+}
+
+// normalize lowercases s, removes punctuation and diacritics from common
+// Latin letters, and collapses whitespace, so that "Sigur Rós" and
+// "sigur ros" compare equal.
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r == '&':
+			b.WriteString(" and ")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(fold(r))
+		case unicode.IsSpace(r) || r == '-' || r == '/':
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+var folds = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a',
+	'ç': 'c',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i',
+	'ñ': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u',
+	'ý': 'y', 'ÿ': 'y',
+}
+
+func fold(r rune) rune {
+	if f, ok := folds[r]; ok {
+		return f
+	}
+	return r
+}
+
+// New returns an instance of the Searcher.
+func New(client *lastfm.Client) (searcher *Searcher) {
+	searcher = &Searcher{
+		album:  album.New(client, "", false),
+		artist: artist.New(client, "", false),
+		track:  track.New(client, "", false),
+	}
+	return
+}
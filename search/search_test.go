@@ -0,0 +1,146 @@
+package search
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// responses are the search results of a stand-in LastFM API for the query
+// "radiohead karma police".
+var responses = map[string]string{
+	"artist.search": `{"results":{"opensearch:totalResults":"1","opensearch:itemsPerPage":"30","artistmatches":{"artist":[
+		{"name":"Radiohead","listeners":"5000000","url":"https://www.last.fm/music/Radiohead","image":[{"#text":"small.png","size":"small"},{"#text":"large.png","size":"large"},{"#text":"","size":"mega"}]}
+	]}}}`,
+	"album.search": `{"results":{"opensearch:totalResults":"2","opensearch:itemsPerPage":"30","albummatches":{"album":[
+		{"name":"OK Computer","artist":"Radiohead","url":"https://www.last.fm/music/Radiohead/OK+Computer"},
+		{"name":"Karma Police","artist":"Radiohead","url":"https://www.last.fm/music/Radiohead/Karma+Police"}
+	]}}}`,
+	"track.search": `{"results":{"opensearch:totalResults":"61","opensearch:itemsPerPage":"30","trackmatches":{"track":[
+		{"name":"Karma Police","artist":"Scala & Kolacny Brothers","listeners":"20000"},
+		{"name":"Karma Police","artist":"Radiohead","listeners":"1500000","mbid":"5f6bf6d6-2b23-4bf7-b24e-2ffa1a4a7c38"}
+	]}}}`,
+}
+
+func newTestSearcher(t *testing.T, failing string) *Searcher {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Query().Get("method")
+		w.Header().Set("Content-Type", "application/json")
+		if method == failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":16,"message":"temporarily unavailable"}`)
+			return
+		}
+		fmt.Fprint(w, responses[method])
+	}))
+	t.Cleanup(srv.Close)
+	client := lastfm.NewWithService(lastfm.Service{Name: "stub", BaseURL: srv.URL}, "key", "secret")
+	return New(&client)
+}
+
+func TestBest(t *testing.T) {
+	s := newTestSearcher(t, "")
+	best, err := s.Best("radiohead karma police")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The track and the single of the same name match the query equally, and
+	// the listeners of the track rank it first.
+	if best.Kind != KindTrack || best.Name != "Karma Police" || best.Artist != "Radiohead" {
+		t.Errorf("got %+v, want the track Karma Police by Radiohead", best)
+	}
+
+	best, err = s.Best("radiohead", KindArtist, KindAlbum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.Kind != KindArtist || best.Name != "Radiohead" || best.Listeners != 5000000 || best.Image != "large.png" {
+		t.Errorf("got %+v, want the artist Radiohead", best)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	resp, err := newTestSearcher(t, "track.search").Search(Query{Text: "radiohead karma police"})
+	if err != nil {
+		t.Fatalf("got %v, want the failure of a single kind to be reported in its page", err)
+	}
+	if page := resp.Pages[KindTrack]; page.Err == nil {
+		t.Errorf("got page %+v for the failed track search", page)
+	}
+	if page := resp.Pages[KindAlbum]; page.Err != nil || page.Page != 1 || page.TotalResults != 2 || page.TotalPages != 1 {
+		t.Errorf("got page %+v for albums", page)
+	}
+	if len(resp.Results) != 3 || resp.Results[0].Kind != KindAlbum || resp.Results[0].Name != "Karma Police" {
+		t.Errorf("got results %+v", resp.Results)
+	}
+	for i := 1; i < len(resp.Results); i++ {
+		if resp.Results[i].Score > resp.Results[i-1].Score {
+			t.Errorf("results are not ordered by score: %+v", resp.Results)
+		}
+	}
+
+	s := newTestSearcher(t, "track.search")
+	if _, err := s.Search(Query{Text: "radiohead karma police", Kinds: []Kind{KindTrack}}); err == nil {
+		t.Error("got no error when every kind failed")
+	}
+}
+
+func TestScore(t *testing.T) {
+	query := "radiohead karma police"
+	tests := []struct {
+		better, worse Result
+	}{
+		// The artist and name of a result match the query in any order.
+		{
+			Result{Kind: KindTrack, Name: "Karma Police", Artist: "Radiohead"},
+			Result{Kind: KindArtist, Name: "Radiohead", Listeners: 5000000},
+		},
+		{
+			Result{Kind: KindTrack, Name: "Karma Police", Artist: "Radiohead"},
+			Result{Kind: KindTrack, Name: "Karma Police", Artist: "Scala & Kolacny Brothers", Listeners: 20000},
+		},
+		{
+			Result{Kind: KindTrack, Name: "Karma Police", Artist: "Radiohead", Listeners: 1500000},
+			Result{Kind: KindAlbum, Name: "Karma Police", Artist: "Radiohead"},
+		},
+		{
+			Result{Kind: KindAlbum, Name: "Karma Police", Artist: "Radiohead"},
+			Result{Kind: KindAlbum, Name: "OK Computer", Artist: "Radiohead"},
+		},
+	}
+	for _, test := range tests {
+		better, worse := score(query, test.better), score(query, test.worse)
+		if better <= worse {
+			t.Errorf("got %v for %+v and %v for %+v", better, test.better, worse, test.worse)
+		}
+	}
+
+	// Without listeners, the score of a match is at most 1-popularityWeight.
+	if got := score("KARMA police, Radiohead!", Result{Name: "Karma Police", Artist: "Radiohead"}); got < 0.85 {
+		t.Errorf("got %v for an exact match in another order and case", got)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"radiohead karma police", "radiohead karma police", 1},
+		{"karma police radiohead", "radiohead karma police", 1},
+		{"", "radiohead", 0},
+		{"abc", "xyz", 0},
+	}
+	for _, test := range tests {
+		// Word order only changes the bigrams across words.
+		if got := Similarity(test.a, test.b); got < test.want-0.1 || got > test.want {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+	if normalize("Sigur Rós & Amiina") != "sigur ros and amiina" {
+		t.Errorf("got %q", normalize("Sigur Rós & Amiina"))
+	}
+}
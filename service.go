@@ -0,0 +1,73 @@
+package lastfm
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrUnsupported is wrapped by the errors returned for API methods which are
+// not supported by the service of a Client.
+var ErrUnsupported = errors.New("unsupported on this service")
+
+// LastFM is the Service profile for Last.fm.
+var LastFM = Service{
+	Name:    "Last.fm",
+	BaseURL: "https://ws.audioscrobbler.com/2.0/",
+	AuthURL: "https://www.last.fm/api/auth/",
+}
+
+// LibreFM is the Service profile for Libre.fm, listing the methods implemented
+// by its GNU FM server.
+var LibreFM = Service{
+	Name:    "Libre.fm",
+	BaseURL: "https://libre.fm/2.0/",
+	AuthURL: "https://libre.fm/api/auth/",
+	Methods: []string{
+		"album.addtags", "album.getinfo", "album.gettags", "album.gettoptags", "album.removetag",
+		"artist.addtags", "artist.getinfo", "artist.getsimilar", "artist.gettags", "artist.gettopalbums",
+		"artist.gettoptags", "artist.gettoptracks", "artist.removetag", "artist.search",
+		"auth.getmobilesession", "auth.getsession", "auth.gettoken",
+		"library.getartists",
+		"tag.getinfo", "tag.gettopalbums", "tag.gettopartists", "tag.gettoptracks",
+		"track.addtags", "track.getinfo", "track.gettags", "track.gettoptags", "track.love",
+		"track.removetag", "track.scrobble", "track.unlove", "track.updatenowplaying",
+		"user.getinfo", "user.getlovedtracks", "user.getpersonaltags", "user.getrecenttracks",
+		"user.gettopartists", "user.gettoptags", "user.gettoptracks", "user.getweeklyartistchart",
+		"user.getweeklychartlist", "user.getweeklytrackchart",
+	},
+}
+
+// UnsupportedError is returned when calling an API method which is not
+// supported by the service of a Client.
+type UnsupportedError struct {
+	Service string
+	Method  string
+}
+
+func (e *UnsupportedError) Error() string {
+	return e.Method + ": " + ErrUnsupported.Error() + " (" + e.Service + ")"
+}
+
+// Unwrap returns ErrUnsupported.
+func (e *UnsupportedError) Unwrap() error {
+	return ErrUnsupported
+}
+
+// Supports reports whether the API method is supported by the service.
+// Methods are compared case-insensitively.
+func (service Service) Supports(method string) bool {
+	if service.Methods == nil {
+		return true
+	}
+	for _, m := range service.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Service returns the Service profile the Client is bound to.
+func (client *Client) Service() Service {
+	return client.service
+}
@@ -0,0 +1,62 @@
+package stats
+
+import (
+	"time"
+)
+
+// Options configures the computation of a Report.
+type Options struct {
+	// Location is the time zone used to split plays into days, weeks and
+	// months, and for the heatmap. Defaults to UTC.
+	Location *time.Location
+	// SessionGap is the largest pause between two plays of the same listening
+	// session. Defaults to 30 minutes.
+	SessionGap time.Duration
+}
+
+// Bucket is the number of plays in a day, week or month starting at Start.
+type Bucket struct {
+	Start time.Time
+	Plays int
+}
+
+// Streak is a run of consecutive days with at least one play.
+type Streak struct {
+	Start time.Time
+	End   time.Time
+	Days  int
+}
+
+// ArtistShare is the number of plays of an artist, and its share of all plays.
+type ArtistShare struct {
+	Artist string
+	Plays  int
+	Share  float64
+}
+
+// Report contains the listening statistics computed from a scrobble history.
+type Report struct {
+	Total int
+	First time.Time
+	Last  time.Time
+
+	// Daily, Weekly and Monthly contain the plays per calendar day, week
+	// (starting on Monday) and month, in chronological order. Periods without
+	// plays are included as empty buckets.
+	Daily   []Bucket
+	Weekly  []Bucket
+	Monthly []Bucket
+
+	// Heatmap contains the plays per weekday, indexed by time.Weekday,
+	// and hour of the day.
+	Heatmap [7][24]int
+
+	LongestStreak Streak
+	// NewArtists contains the number of artists played for the first time
+	// in each month.
+	NewArtists []Bucket
+	TopArtist  ArtistShare
+
+	Sessions               int
+	MedianTracksPerSession float64
+}
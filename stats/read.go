@@ -0,0 +1,30 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+// ReadJSONLines reads a scrobble history stored as JSON Lines, with one
+// user.RecentTrack object per line. Blank lines are skipped.
+func ReadJSONLines(r io.Reader) (plays []user.RecentTrack, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var play user.RecentTrack
+		if err = json.Unmarshal([]byte(text), &play); err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		plays = append(plays, play)
+	}
+	return plays, scanner.Err()
+}
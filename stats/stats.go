@@ -0,0 +1,171 @@
+// Package stats computes listening statistics from a scrobble history,
+// without making any requests to LastFM.
+package stats
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+const defaultSessionGap = 30 * time.Minute
+
+// Compute returns the listening statistics for plays. The plays may be in
+// any order, and tracks which are currently playing are ignored.
+func Compute(plays []user.RecentTrack, opts Options) (report *Report) {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	gap := opts.SessionGap
+	if gap <= 0 {
+		gap = defaultSessionGap
+	}
+
+	var times []time.Time
+	var artists []string
+	sorted := make([]user.RecentTrack, 0, len(plays))
+	for _, play := range plays {
+		if play.NowPlaying || play.Time.IsZero() {
+			continue
+		}
+		sorted = append(sorted, play)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+	for _, play := range sorted {
+		times = append(times, play.Time.In(loc))
+		artists = append(artists, play.Artist)
+	}
+
+	report = &Report{Total: len(times)}
+	if len(times) == 0 {
+		return
+	}
+	report.First, report.Last = times[0], times[len(times)-1]
+
+	report.Daily = buckets(times, startOfDay, 0, 1)
+	report.Weekly = buckets(times, startOfWeek, 0, 7)
+	report.Monthly = buckets(times, startOfMonth, 1, 0)
+
+	for _, t := range times {
+		report.Heatmap[t.Weekday()][t.Hour()]++
+	}
+
+	report.LongestStreak = longestStreak(report.Daily)
+	report.NewArtists, report.TopArtist = artistStats(times, artists, report.Monthly)
+	report.Sessions, report.MedianTracksPerSession = sessions(times, gap)
+	return
+}
+
+// startOfDay returns the first instant of the day of t. Where daylight saving
+// time skips midnight, the day starts at the end of the gap.
+func startOfDay(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for day.Day() != t.Day() {
+		day = day.Add(time.Hour)
+	}
+	return day
+}
+
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return startOfDay(time.Date(t.Year(), t.Month(), t.Day()-offset, 12, 0, 0, 0, t.Location()))
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return startOfDay(time.Date(t.Year(), t.Month(), 1, 12, 0, 0, 0, t.Location()))
+}
+
+// buckets counts the sorted times per period, including empty periods
+// between the first and the last time. Periods are advanced by calendar date
+// from midday, since a day does not always start at midnight.
+func buckets(times []time.Time, start func(time.Time) time.Time, months, days int) (list []Bucket) {
+	current := Bucket{Start: start(times[0])}
+	for _, t := range times {
+		for !start(t).Equal(current.Start) {
+			list = append(list, current)
+			s := current.Start
+			next := time.Date(s.Year(), s.Month()+time.Month(months), s.Day()+days, 12, 0, 0, 0, s.Location())
+			current = Bucket{Start: start(next)}
+		}
+		current.Plays++
+	}
+	return append(list, current)
+}
+
+func longestStreak(daily []Bucket) (longest Streak) {
+	var current Streak
+	for _, day := range daily {
+		if day.Plays == 0 {
+			current = Streak{}
+			continue
+		}
+		if current.Days == 0 {
+			current.Start = day.Start
+		}
+		current.End = day.Start
+		current.Days++
+		if current.Days > longest.Days {
+			longest = current
+		}
+	}
+	return
+}
+
+// artistStats returns the number of artists discovered per month, and the
+// most played artist. Artist names are compared case-insensitively.
+func artistStats(times []time.Time, artists []string, monthly []Bucket) (discovered []Bucket, top ArtistShare) {
+	discovered = make([]Bucket, len(monthly))
+	for i, month := range monthly {
+		discovered[i].Start = month.Start
+	}
+
+	counts := map[string]int{}
+	names := map[string]string{}
+	month := 0
+	for i, t := range times {
+		for !startOfMonth(t).Equal(discovered[month].Start) {
+			month++
+		}
+		key := strings.ToLower(artists[i])
+		if _, seen := counts[key]; !seen {
+			names[key] = artists[i]
+			discovered[month].Plays++
+		}
+		counts[key]++
+	}
+
+	for key, plays := range counts {
+		if plays > top.Plays || (plays == top.Plays && names[key] < top.Artist) {
+			top = ArtistShare{Artist: names[key], Plays: plays}
+		}
+	}
+	top.Share = float64(top.Plays) / float64(len(times))
+	return
+}
+
+// sessions splits the sorted times into listening sessions separated by
+// more than gap, and returns the number of sessions and their median length.
+func sessions(times []time.Time, gap time.Duration) (count int, median float64) {
+	var lengths []int
+	length := 1
+	for i := 1; i < len(times); i++ {
+		if times[i].Sub(times[i-1]) > gap {
+			lengths = append(lengths, length)
+			length = 0
+		}
+		length++
+	}
+	lengths = append(lengths, length)
+	sort.Ints(lengths)
+
+	count = len(lengths)
+	if count%2 == 1 {
+		return count, float64(lengths[count/2])
+	}
+	return count, float64(lengths[count/2-1]+lengths[count/2]) / 2
+}
@@ -0,0 +1,40 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+func TestComputeSkippedMidnight(t *testing.T) {
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skip(err)
+	}
+	plays := []user.RecentTrack{
+		{Artist: "A", Time: time.Date(2018, 11, 3, 12, 0, 0, 0, loc)},
+		{Artist: "B", Time: time.Date(2018, 11, 6, 12, 0, 0, 0, loc)},
+	}
+
+	done := make(chan *Report, 1)
+	go func() { done <- Compute(plays, Options{Location: loc}) }()
+	var report *Report
+	select {
+	case report = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Compute did not return")
+	}
+
+	if len(report.Daily) != 4 {
+		t.Fatalf("got %d daily buckets, want 4", len(report.Daily))
+	}
+	for i, plays := range []int{1, 0, 0, 1} {
+		if report.Daily[i].Plays != plays {
+			t.Errorf("day %d: got %d plays, want %d", i, report.Daily[i].Plays, plays)
+		}
+	}
+	if got := report.Daily[2].Start; got.Day() != 5 || got.Hour() != 0 {
+		t.Errorf("day 2 starts at %v", got)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build go1.18
+// +build go1.18
+
+package tags
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzRead reads arbitrary files, seeded with the fixtures. Run it with
+// `go test -fuzz FuzzRead ./tags`.
+func FuzzRead(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tags, err := Read(bytes.NewReader(data))
+		if err == nil && tags == nil {
+			t.Fatal("no tags and no error")
+		}
+	})
+}
@@ -0,0 +1,352 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// id3Fields maps ID3v2 text frames to Vorbis comment field names.
+var id3Fields = map[string]string{
+	"TPE1": "ARTIST",
+	"TIT2": "TITLE",
+	"TALB": "ALBUM",
+	"TPE2": "ALBUMARTIST",
+	"TRCK": "TRACKNUMBER",
+}
+
+// readMP3 reads the ID3v2 tag at the start of r, if any, followed by the
+// first MPEG audio frame to compute the duration.
+func readMP3(r io.ReadSeeker, tags *Tags) (err error) {
+	var audioStart int64
+	header := make([]byte, 10)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return err
+	}
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		// Check the size against the file before allocating the tag.
+		size := syncsafe(header[6:10])
+		var end int64
+		if end, err = r.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+		if 10+int64(size) > end {
+			return fmt.Errorf("tags: truncated ID3v2 tag: %v", io.ErrUnexpectedEOF)
+		}
+		if _, err = r.Seek(10, io.SeekStart); err != nil {
+			return err
+		}
+		tag := make([]byte, size)
+		if _, err = io.ReadFull(r, tag); err != nil {
+			return fmt.Errorf("tags: truncated ID3v2 tag: %v", err)
+		}
+		if err = readID3(header, tag, tags); err != nil {
+			return err
+		}
+		audioStart = 10 + int64(size)
+		if header[5]&0x10 != 0 {
+			// Footer present.
+			audioStart += 10
+		}
+	}
+	if tags.Duration == 0 {
+		tags.Duration, _ = mpegDuration(r, audioStart)
+	}
+	return nil
+}
+
+func readID3(header, tag []byte, tags *Tags) error {
+	version, flags := header[3], header[5]
+	if version != 3 && version != 4 {
+		return fmt.Errorf("tags: unsupported ID3v2.%d tag", version)
+	}
+	if version == 3 && flags&0x80 != 0 {
+		tag = unsynchronise(tag)
+	}
+	if flags&0x40 != 0 && len(tag) >= 4 {
+		// Skip the extended header.
+		size := int(binary.BigEndian.Uint32(tag[:4]))
+		if version == 4 {
+			size = syncsafe(tag[:4])
+		} else {
+			size += 4
+		}
+		if size > len(tag) {
+			return fmt.Errorf("tags: invalid ID3v2 extended header")
+		}
+		tag = tag[size:]
+	}
+
+	for len(tag) >= 10 && tag[0] != 0 {
+		id := string(tag[:4])
+		size := int(binary.BigEndian.Uint32(tag[4:8]))
+		if version == 4 {
+			// Some taggers write ID3v2.4 frame sizes without the
+			// syncsafe encoding.
+			if safe := syncsafe(tag[4:8]); validFrameAt(tag, 10+safe) || !validFrameAt(tag, 10+size) {
+				size = safe
+			}
+		}
+		frameFlags := tag[9]
+		if 10+size > len(tag) {
+			break
+		}
+		data := tag[10 : 10+size]
+		tag = tag[10+size:]
+
+		if version == 4 {
+			if frameFlags&0x02 != 0 {
+				data = unsynchronise(data)
+			}
+			if frameFlags&0x01 != 0 && len(data) >= 4 {
+				// Data length indicator.
+				data = data[4:]
+			}
+			if frameFlags&0x0c != 0 {
+				// Compressed or encrypted frames are not supported.
+				continue
+			}
+		} else if frameFlags&0xc0 != 0 {
+			continue
+		}
+		readID3Frame(id, data, tags)
+	}
+	return nil
+}
+
+func readID3Frame(id string, data []byte, tags *Tags) {
+	switch {
+	case id == "TXXX":
+		values := id3Text(data)
+		if len(values) < 2 {
+			return
+		}
+		tags.set(values[0], values[1:]...)
+	case id == "UFID":
+		i := bytes.IndexByte(data, 0)
+		if i >= 0 && string(data[:i]) == "http://musicbrainz.org" {
+			tags.RecordingMBID = string(data[i+1:])
+		}
+	case id == "TLEN":
+		if values := id3Text(data); len(values) > 0 {
+			ms, _ := strconv.ParseInt(strings.TrimSpace(values[0]), 10, 64)
+			tags.Duration = time.Duration(ms) * time.Millisecond
+		}
+	case id3Fields[id] != "":
+		tags.set(id3Fields[id], id3Text(data)...)
+	}
+}
+
+// id3Text decodes a text frame. ID3v2.4 frames may contain multiple values
+// separated by null characters, which some taggers also write in ID3v2.3.
+// Multiple artists in ID3v2.3 are otherwise only read from the ARTISTS frame,
+// since "/" is an ambiguous separator.
+func id3Text(data []byte) (values []string) {
+	if len(data) == 0 {
+		return nil
+	}
+	encoding, data := data[0], data[1:]
+	var text string
+	switch encoding {
+	case 0:
+		text = latin1(data)
+	case 1:
+		text = utf16String(data, true)
+	case 2:
+		text = utf16String(data, false)
+	default:
+		text = string(data)
+	}
+	text = strings.TrimRight(text, "\x00")
+	return strings.Split(text, "\x00")
+}
+
+// latin1 decodes ISO-8859-1 text. Many taggers write UTF-8 in frames marked
+// as ISO-8859-1, so valid UTF-8 multi-byte text is kept as-is.
+func latin1(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// utf16String decodes UTF-16 text. With bom set, each null-separated value
+// starts with its own byte order mark, and little-endian is assumed when it
+// is missing.
+func utf16String(data []byte, bom bool) string {
+	var values []string
+	bigEndian := !bom
+	for len(data) >= 2 {
+		if bom && (data[0] == 0xfe && data[1] == 0xff || data[0] == 0xff && data[1] == 0xfe) {
+			bigEndian = data[0] == 0xfe
+			data = data[2:]
+		}
+		var units []uint16
+		for len(data) >= 2 {
+			var unit uint16
+			if bigEndian {
+				unit = binary.BigEndian.Uint16(data)
+			} else {
+				unit = binary.LittleEndian.Uint16(data)
+			}
+			data = data[2:]
+			if unit == 0 {
+				break
+			}
+			units = append(units, unit)
+		}
+		values = append(values, string(utf16.Decode(units)))
+	}
+	return strings.Join(values, "\x00")
+}
+
+// unsynchronise reverses the ID3v2 unsynchronisation scheme, which inserts
+// a null byte after every 0xff byte.
+func unsynchronise(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		out = append(out, data[i])
+		if data[i] == 0xff && i+1 < len(data) && data[i+1] == 0 {
+			i++
+		}
+	}
+	return out
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// validFrameAt reports whether a frame header, padding or the end of the tag
+// starts at offset.
+func validFrameAt(tag []byte, offset int) bool {
+	if offset == len(tag) {
+		return true
+	}
+	if offset+4 > len(tag) {
+		return false
+	}
+	if tag[offset] == 0 {
+		return true
+	}
+	for _, c := range tag[offset : offset+4] {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// mpegBitrates are the bitrates in kbit/s of MPEG-1 and MPEG-2 Layer III.
+var mpegBitrates = [2][16]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+var mpegSampleRates = [3][3]int{
+	{44100, 48000, 32000},
+	{22050, 24000, 16000},
+	{11025, 12000, 8000},
+}
+
+// mpegDuration computes the duration of the MPEG Layer III stream starting at
+// offset, from the Xing or VBRI header of variable bitrate streams, or from
+// the file size for constant bitrate streams.
+func mpegDuration(r io.ReadSeeker, offset int64) (time.Duration, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = r.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	// Search the first frame within the first 64KiB of audio.
+	buf, err := ioutil.ReadAll(io.LimitReader(r, 64<<10))
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xff || buf[i+1]&0xe0 != 0xe0 {
+			continue
+		}
+		h := buf[i : i+4]
+		versionBits, layerBits := (h[1]>>3)&0x03, (h[1]>>1)&0x03
+		bitrateIndex, rateIndex := h[2]>>4, (h[2]>>2)&0x03
+		if versionBits == 1 || layerBits != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+			continue
+		}
+
+		// versionBits are 3 for MPEG-1, 2 for MPEG-2 and 0 for MPEG-2.5.
+		mpeg1 := versionBits == 3
+		version := 0
+		if !mpeg1 {
+			version = 1
+			if versionBits == 0 {
+				version = 2
+			}
+		}
+		sampleRate := mpegSampleRates[version][rateIndex]
+		samplesPerFrame := 1152
+		bitrates := mpegBitrates[0]
+		if !mpeg1 {
+			samplesPerFrame = 576
+			bitrates = mpegBitrates[1]
+		}
+		bitrate := bitrates[bitrateIndex] * 1000
+		mono := h[3]>>6 == 3
+
+		sideInfo := 32
+		switch {
+		case mpeg1 && mono:
+			sideInfo = 17
+		case !mpeg1 && !mono:
+			sideInfo = 17
+		case !mpeg1 && mono:
+			sideInfo = 9
+		}
+		frame := buf[i:]
+		if xing := 4 + sideInfo; len(frame) >= xing+12 {
+			tag := string(frame[xing : xing+4])
+			if (tag == "Xing" || tag == "Info") && frame[xing+7]&0x01 != 0 {
+				frames := binary.BigEndian.Uint32(frame[xing+8:])
+				return time.Duration(frames) * time.Duration(samplesPerFrame) * time.Second / time.Duration(sampleRate), nil
+			}
+		}
+		if len(frame) >= 36+18 && string(frame[36:40]) == "VBRI" {
+			frames := binary.BigEndian.Uint32(frame[36+14:])
+			return time.Duration(frames) * time.Duration(samplesPerFrame) * time.Second / time.Duration(sampleRate), nil
+		}
+
+		size := end - offset - int64(i)
+		if hasID3v1(r, end) {
+			size -= 128
+		}
+		return time.Duration(size*8) * time.Second / time.Duration(bitrate), nil
+	}
+	return 0, fmt.Errorf("tags: no MPEG audio frame found")
+}
+
+func hasID3v1(r io.ReadSeeker, end int64) bool {
+	if end < 128 {
+		return false
+	}
+	tag := make([]byte, 3)
+	if _, err := r.Seek(end-128, io.SeekStart); err != nil {
+		return false
+	}
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return false
+	}
+	return string(tag) == "TAG"
+}
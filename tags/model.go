@@ -0,0 +1,35 @@
+package tags
+
+import (
+	"time"
+)
+
+// Format is the container format of an audio file.
+type Format string
+
+// Formats supported by Read.
+const (
+	FormatMP3  Format = "mp3"
+	FormatFLAC Format = "flac"
+	FormatOgg  Format = "ogg"
+	FormatMP4  Format = "mp4"
+)
+
+// Tags are the metadata read from an audio file.
+type Tags struct {
+	Format Format
+	// Artist is the track artist as credited, and Artists are the individual
+	// artists of multi-artist tags, when present.
+	Artist      string
+	Artists     []string
+	Title       string
+	Album       string
+	AlbumArtist string
+	TrackNumber int
+	Duration    time.Duration
+
+	// RecordingMBID and ReleaseMBID are the MusicBrainz IDs of the recording
+	// and release, as written by MusicBrainz Picard.
+	RecordingMBID string
+	ReleaseMBID   string
+}
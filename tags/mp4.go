@@ -0,0 +1,206 @@
+package tags
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// mp4Fields maps iTunes metadata atoms to Vorbis comment field names.
+var mp4Fields = map[string]string{
+	"\xa9ART": "ARTIST",
+	"\xa9nam": "TITLE",
+	"\xa9alb": "ALBUM",
+	"aART":    "ALBUMARTIST",
+}
+
+// mp4Atom is an atom header, and the offsets of its content in the file.
+type mp4Atom struct {
+	kind       string
+	start, end int64
+}
+
+// readAtoms reads the headers of the atoms between start and end.
+func readAtoms(r io.ReadSeeker, start, end int64) (atoms []mp4Atom, err error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err = r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err = io.ReadFull(r, header[:8]); err != nil {
+			return nil, err
+		}
+		size, headerSize := int64(binary.BigEndian.Uint32(header)), int64(8)
+		switch size {
+		case 0:
+			size = end - offset
+		case 1:
+			if _, err = io.ReadFull(r, header[8:16]); err != nil {
+				return nil, err
+			}
+			size, headerSize = int64(binary.BigEndian.Uint64(header[8:16])), 16
+		}
+		if size < headerSize || size > end-offset {
+			return nil, fmt.Errorf("tags: invalid MP4 atom %q", header[4:8])
+		}
+		atoms = append(atoms, mp4Atom{kind: string(header[4:8]), start: offset + headerSize, end: offset + size})
+		offset += size
+	}
+	return
+}
+
+func findAtom(atoms []mp4Atom, kind string) (mp4Atom, bool) {
+	for _, atom := range atoms {
+		if atom.kind == kind {
+			return atom, true
+		}
+	}
+	return mp4Atom{}, false
+}
+
+func readAtomData(r io.ReadSeeker, atom mp4Atom) (data []byte, err error) {
+	if _, err = r.Seek(atom.start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data = make([]byte, atom.end-atom.start)
+	_, err = io.ReadFull(r, data)
+	return
+}
+
+// readMP4 reads the duration from the `moov.mvhd` atom, and the iTunes
+// metadata from the `moov.udta.meta.ilst` atom.
+func readMP4(r io.ReadSeeker, tags *Tags) (err error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	atoms, err := readAtoms(r, 0, end)
+	if err != nil {
+		return err
+	}
+	moov, ok := findAtom(atoms, "moov")
+	if !ok {
+		return fmt.Errorf("tags: missing MP4 moov atom")
+	}
+	if atoms, err = readAtoms(r, moov.start, moov.end); err != nil {
+		return err
+	}
+
+	if mvhd, ok := findAtom(atoms, "mvhd"); ok {
+		data, err := readAtomData(r, mvhd)
+		if err != nil {
+			return err
+		}
+		tags.Duration = mvhdDuration(data)
+	}
+
+	meta, ok := findAtom(atoms, "meta")
+	if udta, found := findAtom(atoms, "udta"); !ok && found {
+		children, err := readAtoms(r, udta.start, udta.end)
+		if err != nil {
+			return err
+		}
+		meta, ok = findAtom(children, "meta")
+	}
+	if !ok {
+		return nil
+	}
+
+	// The meta atom is a full atom with a version and flags in MPEG-4 files,
+	// but not in QuickTime files.
+	version := make([]byte, 4)
+	if _, err = r.Seek(meta.start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = io.ReadFull(r, version); err != nil {
+		return err
+	}
+	if binary.BigEndian.Uint32(version) == 0 {
+		meta.start += 4
+	}
+	if atoms, err = readAtoms(r, meta.start, meta.end); err != nil {
+		return err
+	}
+	ilst, ok := findAtom(atoms, "ilst")
+	if !ok {
+		return nil
+	}
+	data, err := readAtomData(r, ilst)
+	if err != nil {
+		return err
+	}
+	readIlst(data, tags)
+	return nil
+}
+
+func mvhdDuration(data []byte) time.Duration {
+	var timescale, duration uint64
+	switch {
+	case len(data) >= 20 && data[0] == 0:
+		timescale = uint64(binary.BigEndian.Uint32(data[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(data[16:20]))
+	case len(data) >= 32 && data[0] == 1:
+		timescale = uint64(binary.BigEndian.Uint32(data[20:24]))
+		duration = binary.BigEndian.Uint64(data[24:32])
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(duration) * time.Second / time.Duration(timescale)
+}
+
+// readIlst reads the metadata items of an ilst atom. Each item holds one or
+// more data atoms, and freeform `----` items hold the name of the field in a
+// name atom, as written by MusicBrainz Picard.
+func readIlst(data []byte, tags *Tags) {
+	for _, item := range splitAtoms(data) {
+		var name string
+		var values []string
+		var raw []byte
+		for _, child := range splitAtoms(item.data) {
+			switch child.kind {
+			case "name":
+				if len(child.data) >= 4 {
+					name = string(child.data[4:])
+				}
+			case "data":
+				// Data atoms start with a type and a locale.
+				if len(child.data) >= 8 {
+					raw = child.data[8:]
+					values = append(values, string(raw))
+				}
+			}
+		}
+
+		switch {
+		case item.kind == "----":
+			tags.set(name, values...)
+		case item.kind == "trkn":
+			// Track numbers are a 16-bit number and total after two
+			// bytes of padding.
+			if len(raw) >= 4 {
+				tags.TrackNumber = int(binary.BigEndian.Uint16(raw[2:4]))
+			}
+		case mp4Fields[item.kind] != "":
+			tags.set(mp4Fields[item.kind], values...)
+		}
+	}
+}
+
+type rawAtom struct {
+	kind string
+	data []byte
+}
+
+func splitAtoms(data []byte) (atoms []rawAtom) {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data))
+		if size < 8 || size > len(data) {
+			return
+		}
+		atoms = append(atoms, rawAtom{kind: string(data[4:8]), data: data[8:size]})
+		data = data[size:]
+	}
+	return
+}
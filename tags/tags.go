@@ -0,0 +1,158 @@
+// Package tags reads the metadata of audio files to build LastFM scrobbles.
+//
+// MP3 files with ID3v2.3 and ID3v2.4 tags, FLAC and Ogg Vorbis or Opus files
+// with Vorbis comments, and MP4 (M4A) files with iTunes atoms are supported.
+// The package is pure Go and has no dependencies.
+package tags
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// ErrUnknownFormat is returned when the format of a file is not supported.
+var ErrUnknownFormat = errors.New("tags: unknown audio format")
+
+// ReadFile reads the tags of the audio file at path.
+func ReadFile(path string) (tags *Tags, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read detects the format of an audio file from its content and reads its
+// tags. The duration is read from the tags or computed from the audio stream.
+func Read(r io.ReadSeeker) (tags *Tags, err error) {
+	header := make([]byte, 12)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return nil, ErrUnknownFormat
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	tags = &Tags{}
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		tags.Format = FormatMP3
+		err = readMP3(r, tags)
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		tags.Format = FormatFLAC
+		err = readFLAC(r, tags)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		tags.Format = FormatOgg
+		err = readOgg(r, tags)
+	case bytes.Equal(header[4:8], []byte("ftyp")):
+		tags.Format = FormatMP4
+		err = readMP4(r, tags)
+	case header[0] == 0xff && header[1]&0xe0 == 0xe0:
+		// MP3 without an ID3v2 tag.
+		tags.Format = FormatMP3
+		err = readMP3(r, tags)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	tags.normalize()
+	return
+}
+
+// Scrobble returns the tags as a LastFM Scrobble, without a timestamp.
+// The first of multiple artists is used when the tags have no credited artist.
+func (tags *Tags) Scrobble() lastfm.Scrobble {
+	artist := tags.Artist
+	if artist == "" && len(tags.Artists) > 0 {
+		artist = tags.Artists[0]
+	}
+	return lastfm.Scrobble{
+		Artist:       artist,
+		Track:        tags.Title,
+		Album:        tags.Album,
+		AlbumArtist:  tags.AlbumArtist,
+		TrackNumber:  tags.TrackNumber,
+		MBID:         tags.RecordingMBID,
+		Duration:     int64(tags.Duration / time.Second),
+		ChosenByUser: true,
+	}
+}
+
+// normalize trims the values read from the tags, and drops an album artist
+// equal to the artist.
+func (tags *Tags) normalize() {
+	tags.Artist = clean(tags.Artist)
+	tags.Title = clean(tags.Title)
+	tags.Album = clean(tags.Album)
+	tags.AlbumArtist = clean(tags.AlbumArtist)
+	tags.RecordingMBID = clean(tags.RecordingMBID)
+	tags.ReleaseMBID = clean(tags.ReleaseMBID)
+
+	artists := tags.Artists[:0]
+	for _, artist := range tags.Artists {
+		if artist = clean(artist); artist != "" {
+			artists = append(artists, artist)
+		}
+	}
+	tags.Artists = artists
+	if strings.EqualFold(tags.AlbumArtist, tags.Artist) {
+		tags.AlbumArtist = ""
+	}
+}
+
+// set assigns a tag by its Vorbis comment field name, which is also used
+// for the iTunes and ID3 user-defined fields written by MusicBrainz Picard.
+func (tags *Tags) set(field string, values ...string) {
+	if len(values) == 0 {
+		return
+	}
+	switch strings.ToUpper(field) {
+	case "ARTIST":
+		tags.Artist = values[0]
+		if len(values) > 1 && tags.Artists == nil {
+			tags.Artists = values
+		}
+	case "ARTISTS":
+		tags.Artists = values
+	case "TITLE":
+		tags.Title = values[0]
+	case "ALBUM":
+		tags.Album = values[0]
+	case "ALBUMARTIST", "ALBUM ARTIST":
+		tags.AlbumArtist = values[0]
+	case "TRACKNUMBER":
+		tags.TrackNumber = parseTrackNumber(values[0])
+	case "MUSICBRAINZ_TRACKID", "MUSICBRAINZ TRACK ID":
+		tags.RecordingMBID = values[0]
+	case "MUSICBRAINZ_ALBUMID", "MUSICBRAINZ ALBUM ID":
+		tags.ReleaseMBID = values[0]
+	}
+}
+
+// parseTrackNumber parses track numbers written either as "3" or "3/12".
+func parseTrackNumber(value string) int {
+	value = strings.TrimSpace(strings.SplitN(value, "/", 2)[0])
+	n, _ := strconv.Atoi(value)
+	return n
+}
+
+// clean removes the padding and invalid characters some taggers leave in
+// values.
+func clean(value string) string {
+	value = strings.TrimRight(value, "\x00")
+	if !utf8.ValidString(value) {
+		value = strings.ToValidUTF8(value, "")
+	}
+	return strings.TrimSpace(value)
+}
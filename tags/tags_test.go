@@ -0,0 +1,142 @@
+package tags
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		file string
+		want Tags
+	}{
+		{"id3v23_utf16.mp3", Tags{
+			Format:        FormatMP3,
+			Artist:        "Björk",
+			Title:         "Jóga",
+			Album:         "Homogenic",
+			TrackNumber:   2,
+			Duration:      2006812500,
+			RecordingMBID: "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d",
+			ReleaseMBID:   "f1e2d3c4-b5a6-4978-8695-a4b3c2d1e0f9",
+		}},
+		{"id3v24_multi.mp3", Tags{
+			Format:      FormatMP3,
+			Artist:      "Daft Punk",
+			Artists:     []string{"Daft Punk", "Pharrell Williams", "Nile Rodgers"},
+			Title:       "Get Lucky",
+			Album:       "Random Access Memories",
+			TrackNumber: 8,
+			Duration:    369 * time.Second,
+		}},
+		{"vorbis.flac", Tags{
+			Format:        FormatFLAC,
+			Artist:        "Massive Attack",
+			Artists:       []string{"Massive Attack", "Elizabeth Fraser"},
+			Title:         "Teardrop",
+			Album:         "Mezzanine",
+			TrackNumber:   3,
+			Duration:      330 * time.Second,
+			RecordingMBID: "7d1a1e8e-0c4e-4f1c-9a63-3e0f4b7c2d11",
+		}},
+		{"vorbis.ogg", Tags{
+			Format:      FormatOgg,
+			Artist:      "Björk",
+			Title:       "Hyperballad",
+			Album:       "Post",
+			TrackNumber: 4,
+			Duration:    200 * time.Second,
+		}},
+		{"opus.opus", Tags{
+			Format:      FormatOgg,
+			Artist:      "Aphex Twin",
+			Title:       "Windowlicker",
+			Album:       "Windowlicker",
+			TrackNumber: 1,
+			Duration:    100 * time.Second,
+		}},
+		{"itunes.m4a", Tags{
+			Format:        FormatMP4,
+			Artist:        "Björk",
+			Title:         "Hyperballad",
+			Album:         "Post",
+			TrackNumber:   4,
+			Duration:      321500 * time.Millisecond,
+			RecordingMBID: "5e6f7a8b-9c0d-4e1f-a2b3-c4d5e6f7a8b9",
+		}},
+	}
+	for _, test := range tests {
+		got, err := ReadFile(filepath.Join("testdata", test.file))
+		if err != nil {
+			t.Errorf("%v: %v", test.file, err)
+			continue
+		}
+		if len(got.Artists) == 0 {
+			got.Artists = nil
+		}
+		if !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("%v: got %+v, want %+v", test.file, *got, test.want)
+		}
+	}
+}
+
+func TestScrobble(t *testing.T) {
+	tags := Tags{Artists: []string{"Daft Punk", "Pharrell Williams"}, Title: "Get Lucky", TrackNumber: 8, Duration: 369500 * time.Millisecond}
+	s := tags.Scrobble()
+	if s.Artist != "Daft Punk" || s.Track != "Get Lucky" || s.TrackNumber != 8 || s.Duration != 369 || !s.ChosenByUser {
+		t.Errorf("got scrobble %+v", s)
+	}
+}
+
+func TestReadCorrupt(t *testing.T) {
+	tests := []struct {
+		file string
+		err  string
+	}{
+		{"truncated.mp3", "truncated ID3v2 tag"},
+		{"corrupt.m4a", `invalid MP4 atom "moov"`},
+	}
+	for _, test := range tests {
+		_, err := ReadFile(filepath.Join("testdata", test.file))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: got %v, want an error containing %q", test.file, err, test.err)
+		}
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00WAVEfmt "))); err != ErrUnknownFormat {
+		t.Errorf("got %v for a WAV file, want ErrUnknownFormat", err)
+	}
+	if _, err := Read(bytes.NewReader([]byte("ID3"))); err != ErrUnknownFormat {
+		t.Errorf("got %v for a short file, want ErrUnknownFormat", err)
+	}
+	if _, err := Read(bytes.NewReader([]byte("ID3\x02\x00\x00\x00\x00\x00\x00\x00\x00"))); err == nil || !strings.Contains(err.Error(), "ID3v2.2") {
+		t.Errorf("got %v for an ID3v2.2 tag", err)
+	}
+}
+
+// TestReadTruncated reads every fixture cut short at each length, which must
+// fail or succeed without panicking.
+func TestReadTruncated(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The audio of the MP3 fixture is not needed to read its tags.
+		if len(data) > 4096 {
+			data = data[:4096]
+		}
+		for n := range data {
+			Read(bytes.NewReader(data[:n]))
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// readVorbisComment reads a Vorbis comment block, as used by FLAC, Ogg Vorbis
+// and Opus. Fields may be repeated to hold multiple values.
+func readVorbisComment(data []byte, tags *Tags) error {
+	if len(data) < 4 {
+		return fmt.Errorf("tags: truncated Vorbis comment")
+	}
+	vendor := int(binary.LittleEndian.Uint32(data))
+	if 4+vendor+4 > len(data) {
+		return fmt.Errorf("tags: truncated Vorbis comment")
+	}
+	data = data[4+vendor:]
+	count := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+
+	var order []string
+	fields := map[string][]string{}
+	for i := 0; i < count && len(data) >= 4; i++ {
+		size := int(binary.LittleEndian.Uint32(data))
+		if 4+size > len(data) {
+			return fmt.Errorf("tags: truncated Vorbis comment")
+		}
+		comment := string(data[4 : 4+size])
+		data = data[4+size:]
+
+		parts := strings.SplitN(comment, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		field := strings.ToUpper(parts[0])
+		if _, ok := fields[field]; !ok {
+			order = append(order, field)
+		}
+		fields[field] = append(fields[field], parts[1])
+	}
+	for _, field := range order {
+		tags.set(field, fields[field]...)
+	}
+	return nil
+}
+
+// readFLAC reads the STREAMINFO and VORBIS_COMMENT metadata blocks of a
+// FLAC file.
+func readFLAC(r io.ReadSeeker, tags *Tags) (err error) {
+	if _, err = r.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	for {
+		if _, err = io.ReadFull(r, header); err != nil {
+			return fmt.Errorf("tags: truncated FLAC metadata: %v", err)
+		}
+		last, kind := header[0]&0x80 != 0, header[0]&0x7f
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		switch kind {
+		case 0, 4:
+			// The block is read incrementally, since its size is not
+			// checked against the file.
+			data, err := ioutil.ReadAll(io.LimitReader(r, size))
+			if err != nil {
+				return err
+			}
+			if int64(len(data)) < size {
+				return fmt.Errorf("tags: truncated FLAC metadata: %v", io.ErrUnexpectedEOF)
+			}
+			if kind == 4 {
+				if err = readVorbisComment(data, tags); err != nil {
+					return err
+				}
+			} else if len(data) >= 18 {
+				// The sample rate is 20 bits and the total number of
+				// samples 36 bits, at bit 80 and 108 of STREAMINFO.
+				sampleRate := int64(data[10])<<12 | int64(data[11])<<4 | int64(data[12])>>4
+				samples := int64(data[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(data[14:18]))
+				if sampleRate > 0 {
+					tags.Duration = time.Duration(samples) * time.Second / time.Duration(sampleRate)
+				}
+			}
+		default:
+			if _, err = r.Seek(size, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// oggPage is the header of an Ogg page.
+type oggPage struct {
+	granule  int64
+	serial   uint32
+	segments []byte
+}
+
+func readOggPage(r io.Reader) (page oggPage, err error) {
+	header := make([]byte, 27)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	if string(header[:4]) != "OggS" {
+		return page, fmt.Errorf("tags: invalid Ogg page")
+	}
+	page.granule = int64(binary.LittleEndian.Uint64(header[6:14]))
+	page.serial = binary.LittleEndian.Uint32(header[14:18])
+	page.segments = make([]byte, header[26])
+	_, err = io.ReadFull(r, page.segments)
+	return
+}
+
+// readOgg reads the identification and comment headers of the first logical
+// stream of an Ogg Vorbis or Opus file, and computes the duration from the
+// granule position of the last page.
+func readOgg(r io.ReadSeeker, tags *Tags) (err error) {
+	var packets [][]byte
+	var packet []byte
+	var serial uint32
+	for len(packets) < 2 {
+		page, err := readOggPage(r)
+		if err != nil {
+			return fmt.Errorf("tags: truncated Ogg headers: %v", err)
+		}
+		if len(packets) == 0 && packet == nil {
+			serial = page.serial
+		}
+		for _, size := range page.segments {
+			data := make([]byte, size)
+			if _, err = io.ReadFull(r, data); err != nil {
+				return fmt.Errorf("tags: truncated Ogg headers: %v", err)
+			}
+			if page.serial != serial {
+				continue
+			}
+			packet = append(packet, data...)
+			// A segment shorter than 255 bytes ends the packet.
+			if size < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+	}
+
+	var sampleRate, preSkip int64
+	ident, comment := packets[0], packets[1]
+	switch {
+	case bytes.HasPrefix(ident, []byte("\x01vorbis")) && len(ident) >= 16:
+		sampleRate = int64(binary.LittleEndian.Uint32(ident[12:16]))
+		if !bytes.HasPrefix(comment, []byte("\x03vorbis")) {
+			return fmt.Errorf("tags: missing Vorbis comment header")
+		}
+		comment = comment[7:]
+	case bytes.HasPrefix(ident, []byte("OpusHead")) && len(ident) >= 12:
+		// Opus granule positions are always at 48kHz.
+		sampleRate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(ident[10:12]))
+		if !bytes.HasPrefix(comment, []byte("OpusTags")) {
+			return fmt.Errorf("tags: missing Opus comment header")
+		}
+		comment = comment[8:]
+	default:
+		return fmt.Errorf("tags: unsupported Ogg codec")
+	}
+	if err = readVorbisComment(comment, tags); err != nil {
+		return err
+	}
+
+	if granule := lastGranule(r, serial); granule > preSkip && sampleRate > 0 {
+		tags.Duration = time.Duration(granule-preSkip) * time.Second / time.Duration(sampleRate)
+	}
+	return nil
+}
+
+// lastGranule returns the granule position of the last page of the stream,
+// found in the final 64KiB of the file.
+func lastGranule(r io.ReadSeeker, serial uint32) (granule int64) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0
+	}
+	start := end - 64<<10
+	if start < 0 {
+		start = 0
+	}
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return 0
+	}
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0
+	}
+	for i := bytes.LastIndex(buf, []byte("OggS")); i >= 0; i = bytes.LastIndex(buf[:i], []byte("OggS")) {
+		page, err := readOggPage(bytes.NewReader(buf[i:]))
+		if err == nil && page.serial == serial && page.granule > 0 {
+			return page.granule
+		}
+	}
+	return 0
+}
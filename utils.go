@@ -4,8 +4,7 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"mime"
 	"strconv"
 	"unsafe"
 )
@@ -16,39 +15,56 @@ func (client *Client) Bool2strint(b bool) string {
 	return strconv.Itoa(int(uint8(*(*uint8)(unsafe.Pointer(&b)))))
 }
 
-func (client *Client) parseResponse(resp *http.Response, response interface{}) (err error) {
-	read, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+// ParseInt returns the integer in s, such as a playcount in a LastFM
+// response, or 0 if s is not an integer.
+func ParseInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// LargestImage returns the URL of the last, and largest, non-empty image.
+func LargestImage(images []Image) (url string) {
+	for _, img := range images {
+		if img.Text != "" {
+			url = img.Text
+		}
 	}
-	switch resp.Header.Get("Content-Type") {
+	return
+}
+
+func (client *Client) parseResponse(resp *response, v interface{}) (err error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.contentType)
+	switch mediaType {
 	case "application/json":
-		err = json.Unmarshal(read, &response)
+		err = json.Unmarshal(resp.body, &v)
 	default:
 		base := xmlBase{}
-		err = xml.Unmarshal(read, &base)
+		err = xml.Unmarshal(resp.body, &base)
 		if err != nil {
 			return
 		}
 		if len(base.Inner) > 0 {
-			err = xml.Unmarshal(base.Inner, &response)
+			err = xml.Unmarshal(base.Inner, &v)
 		}
 	}
 	return
 }
 
-func (client *Client) parseError(resp *http.Response, provider *Provider) (err error) {
+func (client *Client) parseError(resp *response, provider *Provider, apiKey string) (code int, err error) {
 	respErr := &Error{}
 	err = client.parseResponse(resp, respErr)
 	if err != nil {
 		return
 	}
+	code = respErr.ErrorCode
+	apiErr := &APIError{Code: code, Message: respErr.Message}
 	switch respErr.ErrorCode {
 	case 2, 3, 5, 6, 7, 13:
-		return fmt.Errorf("%v: %v", provider.Method, respErr.Message)
+		apiErr.text = fmt.Sprintf("%v: %v", provider.Method, respErr.Message)
 	case 10, 26:
-		return fmt.Errorf("%v: %v", client.APIKey, respErr.Message)
+		apiErr.text = fmt.Sprintf("%v: %v", maskKey(apiKey), respErr.Message)
 	default:
-		return fmt.Errorf("Error Code: %v\nMessage:%v", respErr.ErrorCode, respErr.Message)
+		apiErr.text = fmt.Sprintf("Error Code: %v\nMessage:%v", respErr.ErrorCode, respErr.Message)
 	}
+	return code, apiErr
 }
@@ -0,0 +1,48 @@
+package validate
+
+import (
+	"sync"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+// Validator represents a structure to check scrobbles against the reasons
+// LastFM has to ignore them, before they are submitted.
+type Validator struct {
+	counts map[string]int
+	mu     sync.Mutex
+	user   *user.User
+
+	// IgnoredArtists and IgnoredTracks are the lowercase names LastFM
+	// filters out.
+	IgnoredArtists []string
+	IgnoredTracks  []string
+	// DailyLimit is the number of scrobbles accepted per day, and
+	// WarnThreshold the daily count from which warnings are reported.
+	// Either is disabled when 0.
+	DailyLimit    int
+	WarnThreshold int
+	// FutureTolerance is how far in the future a timestamp may be, to allow
+	// for clock skew.
+	FutureTolerance time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Issue is a scrobble LastFM would ignore.
+type Issue struct {
+	// Index is the position of the scrobble in the validated list.
+	Index    int
+	Scrobble lastfm.Scrobble
+	Reason   lastfm.IgnoredReason
+	Message  string
+}
+
+// Report is the outcome of validating a list of scrobbles.
+type Report struct {
+	Valid    []lastfm.Scrobble
+	Invalid  []Issue
+	Warnings []string
+}
@@ -0,0 +1,187 @@
+// Package validate checks scrobbles for the reasons LastFM has to ignore
+// them, such as filtered artist names, timestamps out of range and the daily
+// scrobble limit, so they can be reported before being submitted.
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/user"
+)
+
+// dayFormat is the format of the UTC days scrobbles are counted by.
+const dayFormat = "2006-01-02"
+
+// Check returns the reason LastFM would ignore the scrobble, if any, without
+// counting it towards the daily limit.
+func (v *Validator) Check(scrobble lastfm.Scrobble) (reason lastfm.IgnoredReason, message string) {
+	artist := strings.ToLower(strings.TrimSpace(scrobble.Artist))
+	track := strings.ToLower(strings.TrimSpace(scrobble.Track))
+	switch {
+	case artist == "" || contains(v.IgnoredArtists, artist):
+		return lastfm.IgnoredArtist, fmt.Sprintf("artist %q is filtered by LastFM", scrobble.Artist)
+	case track == "" || contains(v.IgnoredTracks, track):
+		return lastfm.IgnoredTrack, fmt.Sprintf("track %q is filtered by LastFM", scrobble.Track)
+	}
+
+	now := v.Now()
+	played := time.Unix(scrobble.Timestamp, 0)
+	switch {
+	case now.Sub(played) > lastfm.MaxScrobbleAge:
+		return lastfm.IgnoredTimestampTooOld, fmt.Sprintf("timestamp %v is older than %v", played.UTC(), lastfm.MaxScrobbleAge)
+	case played.Sub(now) > v.FutureTolerance:
+		return lastfm.IgnoredTimestampTooNew, fmt.Sprintf("timestamp %v is in the future", played.UTC())
+	}
+	return lastfm.IgnoredNone, ""
+}
+
+// Validate checks the scrobbles, including the daily limit for the scrobbles
+// already recorded on each day. Valid scrobbles are not recorded until
+// passed to Record, once submitted. A warning is reported once for each day
+// whose count is at or above WarnThreshold.
+func (v *Validator) Validate(scrobbles []lastfm.Scrobble) (report Report) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	pending := map[string]int{}
+	warned := map[string]bool{}
+	for i, scrobble := range scrobbles {
+		reason, message := v.Check(scrobble)
+		day := time.Unix(scrobble.Timestamp, 0).UTC().Format(dayFormat)
+		count := v.counts[day] + pending[day]
+		if reason == lastfm.IgnoredNone && v.DailyLimit > 0 && count >= v.DailyLimit {
+			reason, message = lastfm.IgnoredDailyLimit, fmt.Sprintf("%v already has %v scrobbles", day, count)
+		}
+		if reason != lastfm.IgnoredNone {
+			report.Invalid = append(report.Invalid, Issue{Index: i, Scrobble: scrobble, Reason: reason, Message: message})
+			continue
+		}
+
+		pending[day]++
+		if v.WarnThreshold > 0 && count+1 >= v.WarnThreshold && !warned[day] {
+			warned[day] = true
+			warning := fmt.Sprintf("%v reached %v daily scrobbles", day, count+1)
+			if v.DailyLimit > 0 {
+				warning = fmt.Sprintf("%v reached %v of %v daily scrobbles", day, count+1, v.DailyLimit)
+			}
+			report.Warnings = append(report.Warnings, warning)
+		}
+		report.Valid = append(report.Valid, scrobble)
+	}
+	return
+}
+
+// Record counts the submitted scrobbles towards the daily limit.
+func (v *Validator) Record(scrobbles []lastfm.Scrobble) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, scrobble := range scrobbles {
+		v.counts[time.Unix(scrobble.Timestamp, 0).UTC().Format(dayFormat)]++
+	}
+}
+
+// Count returns the number of scrobbles recorded on the UTC day of t.
+func (v *Validator) Count(t time.Time) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.counts[t.UTC().Format(dayFormat)]
+}
+
+// Sync sets the count of the UTC day of t to the number of scrobbles of the
+// user on LastFM.
+func (v *Validator) Sync(t time.Time) error {
+	if v.user == nil {
+		return fmt.Errorf("validate: no LastFM user to sync with")
+	}
+	year, month, day := t.UTC().Date()
+	from := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	rt, err := v.user.GetRecentTracksRange(false, from.Unix(), from.AddDate(0, 0, 1).Unix()-1, 1)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.counts[from.Format(dayFormat)] = rt.Total()
+	return nil
+}
+
+// Scrobbler returns a lastfm.Scrobbler validating scrobbles before passing
+// the valid ones to target. Invalid scrobbles are reported as ignored in the
+// results, with the reason LastFM would give.
+func (v *Validator) Scrobbler(target lastfm.Scrobbler) lastfm.Scrobbler {
+	return &scrobbler{validator: v, target: target}
+}
+
+type scrobbler struct {
+	validator *Validator
+	target    lastfm.Scrobbler
+}
+
+func (s *scrobbler) NowPlaying(scrobble lastfm.Scrobble) error {
+	return s.target.NowPlaying(scrobble)
+}
+
+func (s *scrobbler) Scrobble(scrobbles []lastfm.Scrobble) ([]lastfm.ScrobbleResult, error) {
+	report := s.validator.Validate(scrobbles)
+	results := make([]lastfm.ScrobbleResult, len(scrobbles))
+	invalid := map[int]bool{}
+	for _, issue := range report.Invalid {
+		invalid[issue.Index] = true
+		results[issue.Index] = lastfm.ScrobbleResult{IgnoredCode: issue.Reason, IgnoredMessage: issue.Message}
+	}
+	if len(report.Valid) == 0 {
+		return results, nil
+	}
+
+	submitted, err := s.target.Scrobble(report.Valid)
+	var accepted []lastfm.Scrobble
+	j := 0
+	for i := range scrobbles {
+		if invalid[i] {
+			continue
+		}
+		if j < len(submitted) {
+			results[i] = submitted[j]
+			if submitted[j].Accepted {
+				accepted = append(accepted, report.Valid[j])
+			}
+		}
+		j++
+	}
+	s.validator.Record(accepted)
+	return results, err
+}
+
+func (s *scrobbler) Love(artist, track string) error {
+	return s.target.Love(artist, track)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns an instance of the Validator. The daily counts are synced with
+// the scrobbles of username using Sync, and username may be empty when
+// counting only the scrobbles recorded by the Validator.
+func New(client *lastfm.Client, username string) (validator *Validator) {
+	validator = &Validator{
+		counts:         map[string]int{},
+		IgnoredArtists: []string{"unknown", "[unknown]", "<unknown>", "unknown artist", "[unknown artist]"},
+		IgnoredTracks:  []string{"unknown", "[unknown]", "<unknown>", "unknown track", "[unknown track]"},
+		DailyLimit:     lastfm.MaxDailyScrobbles,
+		WarnThreshold:  2500,
+		Now:            time.Now,
+	}
+	if client != nil && username != "" {
+		validator.user = user.New(client, username)
+	}
+	return
+}
@@ -0,0 +1,158 @@
+package validate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+var now = time.Date(2023, 11, 14, 12, 0, 0, 0, time.UTC)
+
+func newTestValidator() *Validator {
+	v := New(nil, "")
+	v.Now = func() time.Time { return now }
+	v.FutureTolerance = 5 * time.Minute
+	return v
+}
+
+func scrobbleAt(t time.Time) lastfm.Scrobble {
+	return lastfm.Scrobble{Artist: "Cher", Track: "Believe", Timestamp: t.Unix()}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		scrobble lastfm.Scrobble
+		want     lastfm.IgnoredReason
+	}{
+		{"valid", scrobbleAt(now), lastfm.IgnoredNone},
+		{"no artist", lastfm.Scrobble{Track: "Believe", Timestamp: now.Unix()}, lastfm.IgnoredArtist},
+		{"filtered artist", lastfm.Scrobble{Artist: " Unknown Artist ", Track: "Believe", Timestamp: now.Unix()}, lastfm.IgnoredArtist},
+		{"filtered track", lastfm.Scrobble{Artist: "Cher", Track: "[Unknown]", Timestamp: now.Unix()}, lastfm.IgnoredTrack},
+		{"14 days old", scrobbleAt(now.Add(-lastfm.MaxScrobbleAge)), lastfm.IgnoredNone},
+		{"older than 14 days", scrobbleAt(now.Add(-lastfm.MaxScrobbleAge - time.Second)), lastfm.IgnoredTimestampTooOld},
+		{"within the future tolerance", scrobbleAt(now.Add(5 * time.Minute)), lastfm.IgnoredNone},
+		{"beyond the future tolerance", scrobbleAt(now.Add(5*time.Minute + time.Second)), lastfm.IgnoredTimestampTooNew},
+	}
+	v := newTestValidator()
+	for _, test := range tests {
+		if got, message := v.Check(test.scrobble); got != test.want {
+			t.Errorf("%v: got %v (%v), want %v", test.name, got, message, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	v := newTestValidator()
+	v.DailyLimit = 3
+	v.WarnThreshold = 2
+	yesterday := now.Add(-24 * time.Hour)
+	v.Record([]lastfm.Scrobble{scrobbleAt(now)})
+
+	scrobbles := []lastfm.Scrobble{
+		scrobbleAt(now.Add(-time.Minute)),
+		{Artist: "unknown", Track: "Believe", Timestamp: now.Unix()},
+		scrobbleAt(now.Add(-2 * time.Minute)),
+		scrobbleAt(now.Add(-3 * time.Minute)),
+		scrobbleAt(yesterday),
+	}
+	report := v.Validate(scrobbles)
+
+	if want := []lastfm.Scrobble{scrobbles[0], scrobbles[2], scrobbles[4]}; !reflect.DeepEqual(report.Valid, want) {
+		t.Errorf("got valid %+v, want %+v", report.Valid, want)
+	}
+	var reasons []lastfm.IgnoredReason
+	var indexes []int
+	for _, issue := range report.Invalid {
+		reasons = append(reasons, issue.Reason)
+		indexes = append(indexes, issue.Index)
+	}
+	if want := []lastfm.IgnoredReason{lastfm.IgnoredArtist, lastfm.IgnoredDailyLimit}; !reflect.DeepEqual(reasons, want) {
+		t.Errorf("got reasons %v, want %v", reasons, want)
+	}
+	if want := []int{1, 3}; !reflect.DeepEqual(indexes, want) {
+		t.Errorf("got indexes %v, want %v", indexes, want)
+	}
+	// The warning is reported once, when the day reaches the threshold.
+	if want := []string{"2023-11-14 reached 2 of 3 daily scrobbles"}; !reflect.DeepEqual(report.Warnings, want) {
+		t.Errorf("got warnings %q, want %q", report.Warnings, want)
+	}
+
+	// Validating does not count the scrobbles, until they are recorded.
+	if got := v.Count(now); got != 1 {
+		t.Errorf("got count %v, want 1", got)
+	}
+	v.Record(report.Valid)
+	if got, want := v.Count(now), 3; got != want {
+		t.Errorf("got count %v, want %v", got, want)
+	}
+	if got := v.Count(yesterday); got != 1 {
+		t.Errorf("got count %v for yesterday, want 1", got)
+	}
+}
+
+func TestValidateNoLimit(t *testing.T) {
+	v := newTestValidator()
+	v.DailyLimit = 0
+	v.WarnThreshold = 0
+	scrobbles := make([]lastfm.Scrobble, 3)
+	for i := range scrobbles {
+		scrobbles[i] = scrobbleAt(now.Add(-time.Duration(i) * time.Minute))
+	}
+	v.Record(scrobbles)
+
+	report := v.Validate(scrobbles)
+	if len(report.Valid) != 3 || len(report.Invalid) != 0 || len(report.Warnings) != 0 {
+		t.Errorf("got report %+v without a daily limit", report)
+	}
+
+	v.WarnThreshold = 4
+	if report = v.Validate(scrobbles); !reflect.DeepEqual(report.Warnings, []string{"2023-11-14 reached 4 daily scrobbles"}) {
+		t.Errorf("got warnings %q", report.Warnings)
+	}
+}
+
+type stubScrobbler struct {
+	submitted []lastfm.Scrobble
+}
+
+func (s *stubScrobbler) NowPlaying(scrobble lastfm.Scrobble) error { return nil }
+
+func (s *stubScrobbler) Scrobble(scrobbles []lastfm.Scrobble) ([]lastfm.ScrobbleResult, error) {
+	s.submitted = append(s.submitted, scrobbles...)
+	results := make([]lastfm.ScrobbleResult, len(scrobbles))
+	for i, scrobble := range scrobbles {
+		// LastFM ignores the tracks of Cher it has not heard of.
+		results[i].Accepted = scrobble.Track == "Believe"
+	}
+	return results, nil
+}
+
+func (s *stubScrobbler) Love(artist, track string) error { return nil }
+
+func TestScrobbler(t *testing.T) {
+	v := newTestValidator()
+	target := &stubScrobbler{}
+	scrobbles := []lastfm.Scrobble{
+		scrobbleAt(now.Add(-time.Minute)),
+		scrobbleAt(now.Add(-30 * 24 * time.Hour)),
+		{Artist: "Cher", Track: "Unreleased", Timestamp: now.Unix()},
+	}
+	results, err := v.Scrobbler(target).Scrobble(scrobbles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []lastfm.Scrobble{scrobbles[0], scrobbles[2]}; !reflect.DeepEqual(target.submitted, want) {
+		t.Errorf("got submitted %+v, want %+v", target.submitted, want)
+	}
+	if len(results) != 3 || !results[0].Accepted || results[1].IgnoredCode != lastfm.IgnoredTimestampTooOld || results[2].Accepted {
+		t.Errorf("got results %+v", results)
+	}
+	// Only the accepted scrobbles count towards the daily limit.
+	if got := v.Count(now); got != 1 {
+		t.Errorf("got count %v, want 1", got)
+	}
+}
@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+// Source is the media server sending a webhook.
+type Source string
+
+// Media servers supported by the Handler.
+const (
+	SourcePlex     Source = "plex"
+	SourceJellyfin Source = "jellyfin"
+	SourceEmby     Source = "emby"
+)
+
+// Kind is the kind of playback event.
+type Kind string
+
+// Playback events sent by the media servers.
+const (
+	KindPlay     Kind = "play"
+	KindPause    Kind = "pause"
+	KindResume   Kind = "resume"
+	KindStop     Kind = "stop"
+	KindScrobble Kind = "scrobble"
+)
+
+// Event is a playback event parsed from a webhook payload.
+type Event struct {
+	Source Source
+	Kind   Kind
+	// User is the name of the media server user.
+	User string
+	// ItemID identifies the played item on the media server.
+	ItemID string
+	// Music is false for items which are not music tracks, such as movies,
+	// episodes and photos.
+	Music    bool
+	Scrobble lastfm.Scrobble
+	// Position is the playback position, when sent by the media server.
+	Position time.Duration
+	// Completed reports whether the media server considers the item played.
+	Completed bool
+}
+
+// Account is the LastFM account a media server user scrobbles to.
+type Account struct {
+	Username   string
+	SessionKey string
+}
+
+// Handler represents an HTTP handler receiving media server webhooks, and
+// submitting the played tracks to LastFM.
+type Handler struct {
+	accounts map[string]Account
+	client   *lastfm.Client
+	mu       sync.Mutex
+	plays    map[string]time.Time
+	recent   map[string]time.Time
+	tracks   map[string]*track.Track
+
+	// Logger receives ignored events and submission failures, if set.
+	Logger lastfm.Logger
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+type plexPayload struct {
+	Event   string `json:"event"`
+	Account struct {
+		Title string `json:"title"`
+	} `json:"Account"`
+	Metadata struct {
+		Type             string `json:"type"`
+		RatingKey        string `json:"ratingKey"`
+		Title            string `json:"title"`
+		ParentTitle      string `json:"parentTitle"`
+		GrandparentTitle string `json:"grandparentTitle"`
+		OriginalTitle    string `json:"originalTitle"`
+		Index            int    `json:"index"`
+		Duration         int64  `json:"duration"`
+		ViewOffset       int64  `json:"viewOffset"`
+		GUID             []struct {
+			ID string `json:"id"`
+		} `json:"Guid"`
+	} `json:"Metadata"`
+}
+
+type jellyfinPayload struct {
+	NotificationType      string `json:"NotificationType"`
+	NotificationUsername  string `json:"NotificationUsername"`
+	ItemID                string `json:"ItemId"`
+	ItemType              string `json:"ItemType"`
+	Name                  string `json:"Name"`
+	Album                 string `json:"Album"`
+	Artist                string `json:"Artist"`
+	AlbumArtist           string `json:"AlbumArtist"`
+	IndexNumber           int    `json:"IndexNumber"`
+	RunTimeTicks          int64  `json:"RunTimeTicks"`
+	PlaybackPositionTicks int64  `json:"PlaybackPositionTicks"`
+	IsPaused              bool   `json:"IsPaused"`
+	PlayedToCompletion    bool   `json:"PlayedToCompletion"`
+	MusicBrainzTrack      string `json:"Provider_musicbrainztrack"`
+}
+
+type embyPayload struct {
+	Event string `json:"Event"`
+	User  struct {
+		Name string `json:"Name"`
+	} `json:"User"`
+	Item struct {
+		ID           string            `json:"Id"`
+		Type         string            `json:"Type"`
+		Name         string            `json:"Name"`
+		Album        string            `json:"Album"`
+		AlbumArtist  string            `json:"AlbumArtist"`
+		Artists      []string          `json:"Artists"`
+		IndexNumber  int               `json:"IndexNumber"`
+		RunTimeTicks int64             `json:"RunTimeTicks"`
+		ProviderIds  map[string]string `json:"ProviderIds"`
+	} `json:"Item"`
+	PlaybackInfo struct {
+		PositionTicks      int64 `json:"PositionTicks"`
+		PlayedToCompletion bool  `json:"PlayedToCompletion"`
+	} `json:"PlaybackInfo"`
+}
@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// maxPayload is the largest webhook payload accepted, in bytes.
+const maxPayload = 1 << 20
+
+// ticks is the duration of the 100ns ticks used by Jellyfin and Emby.
+const ticks = 100 * time.Nanosecond
+
+// Parse parses the webhook sent by a media server. Plex payloads are sent as
+// a multipart form with a `payload` field, while Jellyfin and Emby payloads
+// are sent as JSON.
+//
+// The Kind of the returned Event is empty for events which are irrelevant to
+// scrobbling.
+func Parse(r *http.Request) (event Event, err error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err = r.ParseMultipartForm(maxPayload); err != nil {
+			return event, err
+		}
+		return ParsePlex([]byte(r.FormValue("payload")))
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPayload))
+	if err != nil {
+		return event, err
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(body, &fields); err != nil {
+		return event, err
+	}
+	switch {
+	case fields["NotificationType"] != nil:
+		return ParseJellyfin(body)
+	case fields["Event"] != nil:
+		return ParseEmby(body)
+	case fields["event"] != nil:
+		return ParsePlex(body)
+	}
+	return event, fmt.Errorf("webhook: unknown payload format")
+}
+
+// ParsePlex parses the JSON payload of a Plex webhook.
+func ParsePlex(payload []byte) (event Event, err error) {
+	var p plexPayload
+	if err = json.Unmarshal(payload, &p); err != nil {
+		return event, fmt.Errorf("webhook: invalid Plex payload: %v", err)
+	}
+	event = Event{
+		Source:   SourcePlex,
+		User:     p.Account.Title,
+		ItemID:   p.Metadata.RatingKey,
+		Music:    p.Metadata.Type == "track",
+		Position: time.Duration(p.Metadata.ViewOffset) * time.Millisecond,
+	}
+	switch p.Event {
+	case "media.play":
+		event.Kind = KindPlay
+	case "media.pause":
+		event.Kind = KindPause
+	case "media.resume":
+		event.Kind = KindResume
+	case "media.stop":
+		event.Kind = KindStop
+	case "media.scrobble":
+		event.Kind = KindScrobble
+		event.Completed = true
+	}
+
+	// Plex stores the track artist in originalTitle when it differs from
+	// the album artist, such as on compilations.
+	artist := p.Metadata.OriginalTitle
+	if artist == "" {
+		artist = p.Metadata.GrandparentTitle
+	}
+	event.Scrobble = lastfm.Scrobble{
+		Artist:       artist,
+		Track:        p.Metadata.Title,
+		Album:        p.Metadata.ParentTitle,
+		AlbumArtist:  p.Metadata.GrandparentTitle,
+		TrackNumber:  p.Metadata.Index,
+		Duration:     p.Metadata.Duration / 1000,
+		ChosenByUser: true,
+	}
+	for _, guid := range p.Metadata.GUID {
+		if strings.HasPrefix(guid.ID, "mbid://") {
+			event.Scrobble.MBID = strings.TrimPrefix(guid.ID, "mbid://")
+		}
+	}
+	return
+}
+
+// ParseJellyfin parses the JSON payload of the Jellyfin webhook plugin, sent
+// using the default generic template.
+func ParseJellyfin(payload []byte) (event Event, err error) {
+	var p jellyfinPayload
+	if err = json.Unmarshal(payload, &p); err != nil {
+		return event, fmt.Errorf("webhook: invalid Jellyfin payload: %v", err)
+	}
+	event = Event{
+		Source:    SourceJellyfin,
+		User:      p.NotificationUsername,
+		ItemID:    p.ItemID,
+		Music:     p.ItemType == "Audio",
+		Position:  time.Duration(p.PlaybackPositionTicks) * ticks,
+		Completed: p.PlayedToCompletion,
+	}
+	switch p.NotificationType {
+	case "PlaybackStart":
+		event.Kind = KindPlay
+	case "PlaybackProgress":
+		// Progress is reported periodically during playback, and is only
+		// relevant when the playback is paused.
+		if p.IsPaused {
+			event.Kind = KindPause
+		}
+	case "PlaybackStop":
+		event.Kind = KindStop
+	}
+
+	artist := p.Artist
+	if artist == "" {
+		artist = p.AlbumArtist
+	}
+	event.Scrobble = lastfm.Scrobble{
+		Artist:       artist,
+		Track:        p.Name,
+		Album:        p.Album,
+		AlbumArtist:  p.AlbumArtist,
+		TrackNumber:  p.IndexNumber,
+		MBID:         p.MusicBrainzTrack,
+		Duration:     int64(time.Duration(p.RunTimeTicks) * ticks / time.Second),
+		ChosenByUser: true,
+	}
+	return
+}
+
+// ParseEmby parses the JSON payload of an Emby webhook.
+func ParseEmby(payload []byte) (event Event, err error) {
+	var p embyPayload
+	if err = json.Unmarshal(payload, &p); err != nil {
+		return event, fmt.Errorf("webhook: invalid Emby payload: %v", err)
+	}
+	event = Event{
+		Source:    SourceEmby,
+		User:      p.User.Name,
+		ItemID:    p.Item.ID,
+		Music:     p.Item.Type == "Audio",
+		Position:  time.Duration(p.PlaybackInfo.PositionTicks) * ticks,
+		Completed: p.PlaybackInfo.PlayedToCompletion,
+	}
+	switch p.Event {
+	case "playback.start":
+		event.Kind = KindPlay
+	case "playback.pause":
+		event.Kind = KindPause
+	case "playback.unpause":
+		event.Kind = KindResume
+	case "playback.stop":
+		event.Kind = KindStop
+	}
+
+	artist := p.Item.AlbumArtist
+	if len(p.Item.Artists) > 0 {
+		artist = p.Item.Artists[0]
+	}
+	event.Scrobble = lastfm.Scrobble{
+		Artist:       artist,
+		Track:        p.Item.Name,
+		Album:        p.Item.Album,
+		AlbumArtist:  p.Item.AlbumArtist,
+		TrackNumber:  p.Item.IndexNumber,
+		MBID:         p.Item.ProviderIds["MusicBrainzTrack"],
+		Duration:     int64(time.Duration(p.Item.RunTimeTicks) * ticks / time.Second),
+		ChosenByUser: true,
+	}
+	return
+}
@@ -0,0 +1,206 @@
+// Package webhook receives the playback webhooks of Plex, Jellyfin and Emby
+// media servers, and scrobbles the played music tracks to LastFM.
+//
+// Each media server user is mapped to a LastFM account. Now playing updates
+// are sent when playback starts or resumes. Plex tracks are scrobbled on its
+// `media.scrobble` event, and Jellyfin and Emby tracks when playback stops
+// after the track played for half its duration or 4 minutes.
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+	"git.maych.in/thunderbottom/lastfm-go/api/track"
+)
+
+// retention is how long play and scrobble records are kept for.
+const retention = 24 * time.Hour
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	event, err := Parse(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = h.Handle(event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handle submits the playback event to the LastFM account of its user.
+// Events for other media, unknown users and duplicate deliveries are ignored.
+func (h *Handler) Handle(event Event) (err error) {
+	if event.Kind == "" || !event.Music || event.Scrobble.Artist == "" || event.Scrobble.Track == "" {
+		return nil
+	}
+	t, ok := h.track(event.User)
+	if !ok {
+		h.log("webhook user has no LastFM account", "source", event.Source, "user", event.User)
+		return nil
+	}
+
+	// The records are updated before submitting, so that webhooks for other
+	// plays are not held up by LastFM, and a redelivered webhook is ignored
+	// while its submission is in progress.
+	h.mu.Lock()
+	now := h.Now()
+	h.prune(now)
+	key := string(event.Source) + "/" + strings.ToLower(event.User) + "/" + event.ItemID
+	var scrobble lastfm.Scrobble
+	var submit bool
+	switch event.Kind {
+	case KindPlay:
+		h.plays[key] = now.Add(-event.Position)
+	case KindStop:
+		if event.Completed || played(event) {
+			scrobble, submit = h.claim(key, event, now)
+		}
+		delete(h.plays, key)
+	case KindScrobble:
+		scrobble, submit = h.claim(key, event, now)
+	}
+	h.mu.Unlock()
+
+	switch event.Kind {
+	case KindPlay, KindResume:
+		_, err = t.UpdateNowPlaying(event.Scrobble)
+	case KindStop, KindScrobble:
+		if submit {
+			if _, err = t.Scrobble([]lastfm.Scrobble{scrobble}); err != nil && retryable(err) {
+				h.release(key, now, scrobble)
+			}
+		}
+	}
+	return
+}
+
+// release removes the scrobble claimed at now by a failed submission, so that
+// the redelivered webhook submits it again.
+func (h *Handler) release(key string, now time.Time, scrobble lastfm.Scrobble) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if claimed, ok := h.recent[key]; !ok || !claimed.Equal(now) {
+		return
+	}
+	delete(h.recent, key)
+	if _, playing := h.plays[key]; !playing {
+		h.plays[key] = time.Unix(scrobble.Timestamp, 0)
+	}
+}
+
+// retryable reports whether a failed submission may succeed when it is sent
+// again: network failures, and LastFM errors for temporary outages and rate
+// limits.
+func retryable(err error) bool {
+	var apiErr *lastfm.APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	switch apiErr.Code {
+	case 8, 11, 16, 29:
+		return true
+	}
+	return false
+}
+
+// claim records the scrobble of the event and returns it with its timestamp,
+// unless the same item was scrobbled too recently to have been played again.
+func (h *Handler) claim(key string, event Event, now time.Time) (scrobble lastfm.Scrobble, ok bool) {
+	duration := time.Duration(event.Scrobble.Duration) * time.Second
+	window := duration / 2
+	if window < 30*time.Second {
+		window = 30 * time.Second
+	}
+	if last, seen := h.recent[key]; seen && now.Sub(last) < window {
+		h.log("webhook duplicate scrobble ignored", "source", event.Source, "user", event.User, "item", event.ItemID)
+		return scrobble, false
+	}
+	h.recent[key] = now
+
+	scrobble = event.Scrobble
+	start, playing := h.plays[key]
+	if !playing {
+		start = now.Add(-duration)
+	}
+	scrobble.Timestamp = start.Unix()
+	return scrobble, true
+}
+
+// played reports whether the playback position of a stopped track reached
+// the LastFM scrobble threshold.
+func played(event Event) bool {
+	duration := time.Duration(event.Scrobble.Duration) * time.Second
+	if duration <= 30*time.Second {
+		return false
+	}
+	threshold := duration / 2
+	if threshold > 4*time.Minute {
+		threshold = 4 * time.Minute
+	}
+	return event.Position >= threshold
+}
+
+func (h *Handler) prune(now time.Time) {
+	for key, t := range h.plays {
+		if now.Sub(t) > retention {
+			delete(h.plays, key)
+		}
+	}
+	for key, t := range h.recent {
+		if now.Sub(t) > retention {
+			delete(h.recent, key)
+		}
+	}
+}
+
+// track returns the track API bound to the LastFM session of a media server
+// user, matched case-insensitively.
+func (h *Handler) track(user string) (*track.Track, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	user = strings.ToLower(user)
+	if t, ok := h.tracks[user]; ok {
+		return t, true
+	}
+	for name, account := range h.accounts {
+		if strings.ToLower(name) != user {
+			continue
+		}
+		client := *h.client
+		client.SetSessionKey(account.SessionKey)
+		t := track.New(&client, account.Username, false)
+		h.tracks[user] = t
+		return t, true
+	}
+	return nil, false
+}
+
+func (h *Handler) log(msg string, args ...interface{}) {
+	if h.Logger != nil {
+		h.Logger.Info(msg, args...)
+	}
+}
+
+// New returns an instance of the Handler submitting to LastFM using client.
+// accounts maps the names of media server users to their LastFM accounts.
+func New(client *lastfm.Client, accounts map[string]Account) (handler *Handler) {
+	handler = &Handler{
+		accounts: accounts,
+		client:   client,
+		plays:    map[string]time.Time{},
+		recent:   map[string]time.Time{},
+		tracks:   map[string]*track.Track{},
+		Now:      time.Now,
+	}
+	return
+}
@@ -0,0 +1,266 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"git.maych.in/thunderbottom/lastfm-go"
+)
+
+// stub is a stand-in LastFM API recording the submitted methods.
+type stub struct {
+	mu      sync.Mutex
+	methods []string
+	params  []map[string]string
+	// hook, if set, is called before answering, and fails the request when
+	// it returns false.
+	hook func(params map[string]string) bool
+}
+
+func (s *stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Query().Get("method")
+	params := map[string]string{}
+	for key := range r.URL.Query() {
+		params[key] = r.URL.Query().Get(key)
+	}
+	s.mu.Lock()
+	s.methods = append(s.methods, method)
+	s.params = append(s.params, params)
+	hook := s.hook
+	s.mu.Unlock()
+
+	if hook != nil && !hook(params) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":16,"message":"Service temporarily unavailable"}`)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	body := `<nowplaying><track corrected="0">T</track><artist corrected="0">A</artist><ignoredMessage code="0"></ignoredMessage></nowplaying>`
+	if method == "track.scrobble" {
+		body = `<scrobbles accepted="1" ignored="0"><scrobble><track corrected="0">T</track><artist corrected="0">A</artist><timestamp>1</timestamp><ignoredMessage code="0"></ignoredMessage></scrobble></scrobbles>`
+	}
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><lfm status="ok">`+body+`</lfm>`)
+}
+
+func (s *stub) calls() (methods []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append(methods, s.methods...)
+}
+
+func newHandler(t *testing.T) (*Handler, *stub, *time.Time) {
+	s := &stub{}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	client := lastfm.NewWithService(lastfm.Service{Name: "stub", BaseURL: srv.URL}, "key", "secret")
+	h := New(&client, map[string]Account{
+		"Alice": {Username: "alice-fm", SessionKey: "sk-alice"},
+		"bob":   {Username: "bob-fm", SessionKey: "sk-bob"},
+		"carol": {Username: "carol-fm", SessionKey: "sk-carol"},
+	})
+	now := time.Unix(1700000000, 0)
+	h.Now = func() time.Time { return now }
+	return h, s, &now
+}
+
+func fixture(t *testing.T, name string) []byte {
+	body, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// request returns the webhook request delivering the fixture, as a multipart
+// form for Plex and as JSON otherwise.
+func request(t *testing.T, name string) *http.Request {
+	body := fixture(t, name)
+	if !strings.HasPrefix(name, "plex_") {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	if err := form.WriteField("payload", string(body)); err != nil {
+		t.Fatal(err)
+	}
+	form.Close()
+	r := httptest.NewRequest(http.MethodPost, "/", &buf)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+func deliver(t *testing.T, h *Handler, name string) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request(t, name))
+	return w.Code
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		source    Source
+		kind      Kind
+		user      string
+		music     bool
+		artist    string
+		track     string
+		completed bool
+	}{
+		{"plex_play.json", SourcePlex, KindPlay, "alice", true, "Aphex Twin", "Windowlicker", false},
+		{"plex_pause.json", SourcePlex, KindPause, "alice", true, "Aphex Twin", "Windowlicker", false},
+		{"plex_resume.json", SourcePlex, KindResume, "alice", true, "Aphex Twin", "Windowlicker", false},
+		{"plex_scrobble.json", SourcePlex, KindScrobble, "alice", true, "Aphex Twin", "Windowlicker", true},
+		{"plex_stop.json", SourcePlex, KindStop, "alice", true, "Aphex Twin", "Windowlicker", false},
+		{"plex_movie_play.json", SourcePlex, KindPlay, "alice", false, "", "Heat", false},
+		{"jellyfin_start.json", SourceJellyfin, KindPlay, "bob", true, "Massive Attack", "Teardrop", false},
+		{"jellyfin_progress_paused.json", SourceJellyfin, KindPause, "bob", true, "Massive Attack", "Teardrop", false},
+		{"jellyfin_stop.json", SourceJellyfin, KindStop, "bob", true, "Massive Attack", "Teardrop", true},
+		{"jellyfin_stop_skipped.json", SourceJellyfin, KindStop, "bob", true, "Massive Attack", "Teardrop", false},
+		{"jellyfin_episode_start.json", SourceJellyfin, KindPlay, "bob", false, "", "Pilot", false},
+		{"emby_start.json", SourceEmby, KindPlay, "carol", true, "Björk", "Hyperballad", false},
+		{"emby_pause.json", SourceEmby, KindPause, "carol", true, "Björk", "Hyperballad", false},
+		{"emby_unpause.json", SourceEmby, KindResume, "carol", true, "Björk", "Hyperballad", false},
+		{"emby_stop.json", SourceEmby, KindStop, "carol", true, "Björk", "Hyperballad", true},
+	}
+	for _, test := range tests {
+		event, err := Parse(request(t, test.fixture))
+		if err != nil {
+			t.Errorf("%v: %v", test.fixture, err)
+			continue
+		}
+		if event.Source != test.source || event.Kind != test.kind || event.User != test.user || event.Music != test.music {
+			t.Errorf("%v: got %v %v %q music=%v", test.fixture, event.Source, event.Kind, event.User, event.Music)
+		}
+		if test.music && event.Scrobble.Artist != test.artist {
+			t.Errorf("%v: got artist %q, want %q", test.fixture, event.Scrobble.Artist, test.artist)
+		}
+		if event.Scrobble.Track != test.track || event.Completed != test.completed {
+			t.Errorf("%v: got track %q completed=%v", test.fixture, event.Scrobble.Track, event.Completed)
+		}
+	}
+}
+
+func TestHandlePlex(t *testing.T) {
+	h, s, now := newHandler(t)
+
+	for _, name := range []string{"plex_movie_play.json", "plex_play.json", "plex_pause.json"} {
+		if code := deliver(t, h, name); code != http.StatusNoContent {
+			t.Fatalf("%v: got status %v", name, code)
+		}
+	}
+	*now = now.Add(3 * time.Minute)
+	for _, name := range []string{"plex_resume.json", "plex_scrobble.json", "plex_stop.json"} {
+		if code := deliver(t, h, name); code != http.StatusNoContent {
+			t.Fatalf("%v: got status %v", name, code)
+		}
+	}
+
+	want := []string{"track.updatenowplaying", "track.updatenowplaying", "track.scrobble"}
+	if got := s.calls(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got calls %v, want %v", got, want)
+	}
+	params := s.params[2]
+	if params["sk"] != "sk-alice" || params["timestamp[1]"] != "1700000000" || params["track[1]"] != "Windowlicker" {
+		t.Errorf("got scrobble params %v", params)
+	}
+}
+
+func TestHandleJellyfin(t *testing.T) {
+	h, s, now := newHandler(t)
+
+	deliver(t, h, "jellyfin_episode_start.json")
+	deliver(t, h, "jellyfin_start.json")
+	deliver(t, h, "jellyfin_progress_paused.json")
+	deliver(t, h, "jellyfin_stop_skipped.json")
+	deliver(t, h, "jellyfin_start.json")
+	*now = now.Add(6 * time.Minute)
+	deliver(t, h, "jellyfin_stop.json")
+	// A redelivered webhook is not scrobbled again.
+	deliver(t, h, "jellyfin_stop.json")
+
+	want := []string{"track.updatenowplaying", "track.updatenowplaying", "track.scrobble"}
+	if got := s.calls(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got calls %v, want %v", got, want)
+	}
+	if got := s.params[2]["timestamp[1]"]; got != "1700000000" {
+		t.Errorf("got timestamp %v, want the start of the play", got)
+	}
+}
+
+func TestHandleFailedScrobble(t *testing.T) {
+	h, s, now := newHandler(t)
+	failed := false
+	s.hook = func(params map[string]string) bool {
+		if params["method"] != "track.scrobble" || failed {
+			return true
+		}
+		failed = true
+		return false
+	}
+
+	deliver(t, h, "emby_start.json")
+	deliver(t, h, "emby_unpause.json")
+	*now = now.Add(6 * time.Minute)
+	if code := deliver(t, h, "emby_stop.json"); code != http.StatusBadGateway {
+		t.Fatalf("got status %v for a failed scrobble, want %v", code, http.StatusBadGateway)
+	}
+	// The media server redelivers the webhook, which is scrobbled this time.
+	if code := deliver(t, h, "emby_stop.json"); code != http.StatusNoContent {
+		t.Fatalf("got status %v for a redelivered webhook, want %v", code, http.StatusNoContent)
+	}
+	// A further redelivery is a duplicate.
+	if code := deliver(t, h, "emby_stop.json"); code != http.StatusNoContent {
+		t.Fatalf("got status %v for a duplicate webhook, want %v", code, http.StatusNoContent)
+	}
+
+	want := []string{"track.updatenowplaying", "track.updatenowplaying", "track.scrobble", "track.scrobble"}
+	if got := s.calls(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got calls %v, want %v", got, want)
+	}
+	if got := s.params[3]["timestamp[1]"]; got != "1700000000" {
+		t.Errorf("got timestamp %v, want the start of the play", got)
+	}
+}
+
+func TestHandleConcurrent(t *testing.T) {
+	h, s, _ := newHandler(t)
+	release := make(chan struct{})
+	s.hook = func(params map[string]string) bool {
+		if params["sk"] == "sk-carol" {
+			<-release
+		}
+		return true
+	}
+
+	// The now playing update of carol blocks, and must not hold up bob.
+	blocked := make(chan int)
+	go func() { blocked <- deliver(t, h, "emby_start.json") }()
+	for len(s.calls()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan int)
+	go func() { done <- deliver(t, h, "jellyfin_start.json") }()
+	select {
+	case code := <-done:
+		if code != http.StatusNoContent {
+			t.Errorf("got status %v", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("webhook waited for the submission of another user")
+	}
+	close(release)
+	<-blocked
+}